@@ -0,0 +1,199 @@
+package ecsCmd
+
+import (
+	"fmt"
+	"sort"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	gyaml "github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	secretsModeCSI          = "csi"
+	secretsStoreCSIDriver   = "secrets-store.csi.k8s.io"
+	secretsStoreVolumeName  = "secrets-store"
+	secretsStoreMountPath   = "/mnt/secrets-store"
+	secretsStoreAWSProvider = "aws"
+)
+
+var (
+	secretsStoreGroupVersion    = schema.GroupVersion{Group: "secrets-store.csi.x-k8s.io", Version: "v1"}
+	secretProviderClassResource = secretsStoreGroupVersion.WithResource("secretproviderclasses")
+)
+
+// Sync the mounted secrets to K8s secrets so they can be used as environment variables
+var syncCSISecrets bool
+
+var secretProviderClasses []unstructured.Unstructured
+
+// Objects to mount with the AWS provider, collected while converting a task definition
+var secretProviderObjects []secretProviderObject
+
+var csiSecretRegion string
+
+// An entry of the "objects" parameter of the AWS provider
+type secretProviderObject struct {
+	ObjectName         string          `json:"objectName"`
+	ObjectType         string          `json:"objectType"`
+	ObjectAlias        string          `json:"objectAlias,omitempty"`
+	ObjectVersion      string          `json:"objectVersion,omitempty"`
+	ObjectVersionLabel string          `json:"objectVersionLabel,omitempty"`
+	JmesPath           []jmesPathEntry `json:"jmesPath,omitempty"`
+
+	refs []secretReference
+}
+
+type jmesPathEntry struct {
+	Path        string `json:"path"`
+	ObjectAlias string `json:"objectAlias"`
+}
+
+// Name of the file the referenced value is mounted as
+func secretProviderAlias(ref secretReference) string {
+	return ref.secretName + "_" + ref.secretKey
+}
+
+// Adds the reference to the objects mounted by the Secrets Store CSI driver
+func generateSecretProviderObject(ref secretReference) error {
+	region := ref.region
+	if region == "" {
+		var err error
+		if region, err = getDefaultRegion(); err != nil {
+			return err
+		}
+	}
+	// The provider reads all the objects of a SecretProviderClass from a single region
+	if csiSecretRegion == "" {
+		csiSecretRegion = region
+	} else if region != csiSecretRegion {
+		root.PrintWarning("Secret", ref.secretId, "is in", region, "but the SecretProviderClass reads from", csiSecretRegion+", use its ARN from", csiSecretRegion, "or a replica")
+	}
+
+	alias := secretProviderAlias(ref)
+
+	if ref.service == "ssm" {
+		for _, obj := range secretProviderObjects {
+			if obj.ObjectName == ref.secretId {
+				return nil
+			}
+		}
+		secretProviderObjects = append(secretProviderObjects, secretProviderObject{
+			ObjectName:  ref.secretId,
+			ObjectType:  "ssmparameter",
+			ObjectAlias: alias,
+			refs:        []secretReference{ref},
+		})
+		return nil
+	}
+
+	// Keys of the same secret version are extracted from one object with JMES paths
+	for i := range secretProviderObjects {
+		obj := &secretProviderObjects[i]
+		if obj.ObjectName != ref.secretId || obj.ObjectVersion != ref.versionId || obj.ObjectVersionLabel != ref.versionStage {
+			continue
+		}
+		for _, jp := range obj.JmesPath {
+			if jp.ObjectAlias == alias {
+				return nil
+			}
+		}
+		obj.JmesPath = append(obj.JmesPath, jmesPathEntry{Path: ref.jsonKey, ObjectAlias: alias})
+		obj.refs = append(obj.refs, ref)
+		return nil
+	}
+
+	// The whole secret is mounted too, named after the K8s secret rather than the ARN
+	secretAlias := ref.secretName
+	if ref.versionId != "" || ref.versionStage != "" {
+		secretAlias = secretAlias + "_" + ref.versionStage + ref.versionId
+	}
+
+	secretProviderObjects = append(secretProviderObjects, secretProviderObject{
+		ObjectName:         ref.secretId,
+		ObjectType:         "secretsmanager",
+		ObjectAlias:        secretAlias,
+		ObjectVersion:      ref.versionId,
+		ObjectVersionLabel: ref.versionStage,
+		JmesPath:           []jmesPathEntry{{Path: ref.jsonKey, ObjectAlias: alias}},
+		refs:               []secretReference{ref},
+	})
+	return nil
+}
+
+// Generates the SecretProviderClass of a task family, returns the pod volume mounting it
+func generateSecretProviderClass(family string, namespace string) (corev1.Volume, bool) {
+	if len(secretProviderObjects) == 0 {
+		return corev1.Volume{}, false
+	}
+
+	objects, err := gyaml.Marshal(secretProviderObjects)
+	if err != nil {
+		fmt.Println("Unable to generate SecretProviderClass objects:", err)
+		return corev1.Volume{}, false
+	}
+
+	name := kubeName(family)
+	spec := map[string]interface{}{
+		"provider": secretsStoreAWSProvider,
+		"parameters": map[string]interface{}{
+			"region":  csiSecretRegion,
+			"objects": string(objects),
+		},
+	}
+
+	if syncCSISecrets {
+		spec["secretObjects"] = secretObjectsFromProviderObjects()
+	}
+
+	spc := unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	spc.SetAPIVersion(secretsStoreGroupVersion.String())
+	spc.SetKind("SecretProviderClass")
+	spc.SetName(name)
+	spc.SetNamespace(namespace)
+	secretProviderClasses = append(secretProviderClasses, spc)
+
+	readOnly := true
+	return corev1.Volume{
+		Name: secretsStoreVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			CSI: &corev1.CSIVolumeSource{
+				Driver:           secretsStoreCSIDriver,
+				ReadOnly:         &readOnly,
+				VolumeAttributes: map[string]string{"secretProviderClass": name},
+			},
+		},
+	}, true
+}
+
+// K8s secrets synced from the mounted files, with the same names and keys as in the other secrets modes
+func secretObjectsFromProviderObjects() []interface{} {
+	data := make(map[string][]interface{})
+	for _, obj := range secretProviderObjects {
+		for _, ref := range obj.refs {
+			data[ref.secretName] = append(data[ref.secretName], map[string]interface{}{
+				"objectName": secretProviderAlias(ref),
+				"key":        ref.secretKey,
+			})
+		}
+	}
+
+	var names []string
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var secretObjects []interface{}
+	for _, name := range names {
+		secretObjects = append(secretObjects, map[string]interface{}{
+			"secretName": name,
+			"type":       string(corev1.SecretTypeOpaque),
+			"data":       data[name],
+		})
+	}
+	return secretObjects
+}
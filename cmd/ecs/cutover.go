@@ -0,0 +1,286 @@
+package ecsCmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/homedir"
+)
+
+// Original desired count of an ECS service being cut over, kept until it is restored
+type cutoverRecord struct {
+	Cluster      string    `json:"cluster"`
+	Service      string    `json:"service"`
+	DesiredCount int32     `json:"desiredCount"`
+	Namespace    string    `json:"namespace"`
+	Deployment   string    `json:"deployment"`
+	Started      time.Time `json:"started"`
+}
+
+// Scale the ECS service down after migrate-service
+var cutoverService bool
+
+var (
+	cutoverSteps int32
+	cutoverPause time.Duration
+)
+
+// cutoverCmd represents the cutover command
+var cutoverCmd = &cobra.Command{
+	Use:   "cutover",
+	Short: "Scales an ECS service down once its K8s deployment is healthy",
+	Long: `Scales an ECS service down once the K8s deployment migrated from it is available. The desired count is reduced in
+--cutover-steps steps with --cutover-pause between them, the deployment must still be available after each pause or the
+original count is restored. The original count is recorded so the cutover can be reverted with --restore. For example:
+
+	ecs2k8s ecs cutover --cluster xxxx --service xxxx --namespace xxxx
+	ecs2k8s ecs cutover --cluster xxxx --service xxxx --restore`,
+	// --dry-run takes its value after =, a value after a space would be an argument
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		service, _ := cmd.Flags().GetString("service")
+		cluster, _ := cmd.Flags().GetString("cluster")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		restore, _ := cmd.Flags().GetBool("restore")
+
+		if service == "" || cluster == "" {
+			return errors.New("service and cluster required")
+		}
+
+		if err := readApplyFlags(cmd); err != nil {
+			return err
+		}
+		if err := readCutoverFlags(cmd); err != nil {
+			return err
+		}
+
+		if restore {
+			return restoreService(cluster, service)
+		}
+
+		if namespace == "" {
+			return errors.New("namespace required")
+		}
+
+		svc, err := getService(cluster, service)
+		if err != nil {
+			return err
+		}
+		return cutover(svc, namespace, taskDefinitionFamily(*svc.TaskDefinition))
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(cutoverCmd)
+	cutoverCmd.Flags().Bool("restore", false, "Restore the desired count the ECS service had before the cutover")
+}
+
+func readCutoverFlags(cmd *cobra.Command) error {
+	cutoverService, _ = cmd.Flags().GetBool("cutover")
+	cutoverSteps, _ = cmd.Flags().GetInt32("cutover-steps")
+	cutoverPause, _ = cmd.Flags().GetDuration("cutover-pause")
+
+	if cutoverSteps < 1 {
+		return fmt.Errorf("invalid cutover steps %d - must be at least 1", cutoverSteps)
+	}
+	return nil
+}
+
+// Family of a task definition ARN, arn:aws:ecs:region:account:task-definition/family:revision
+func taskDefinitionFamily(taskDefinitionArn string) string {
+	family := taskDefinitionArn[strings.LastIndex(taskDefinitionArn, "/")+1:]
+	if i := strings.LastIndex(family, ":"); i >= 0 {
+		family = family[:i]
+	}
+	return family
+}
+
+func cutoverPath(cluster string, service string) string {
+	return filepath.Join(homedir.HomeDir(), ".ecs2k8s", "cutovers", kubeName(cluster)+"-"+kubeName(service)+".json")
+}
+
+// Reduces the desired count of the service step by step while the deployment stays available
+func cutover(svc types.Service, namespace string, deploymentName string) error {
+	cluster, service := clusterName(*svc.ClusterArn), *svc.ServiceName
+
+	// A cutover that was interrupted already lowered the desired count, keep the count it started from
+	record, err := loadCutoverRecord(cluster, service)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &cutoverRecord{
+			Cluster:      cluster,
+			Service:      service,
+			DesiredCount: svc.DesiredCount,
+			Namespace:    namespace,
+			Deployment:   deploymentName,
+			Started:      time.Now(),
+		}
+	}
+
+	d := appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName, Namespace: namespace}}
+
+	var targets []int32
+	for i := int32(1); i <= cutoverSteps; i++ {
+		target := record.DesiredCount * (cutoverSteps - i) / cutoverSteps
+		if target < svc.DesiredCount && (len(targets) == 0 || target < targets[len(targets)-1]) {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("ECS service", service, "is already scaled down")
+		return nil
+	}
+
+	fmt.Println("Cutting over ECS service", service, "to deployment", deploymentName+", desired count", svc.DesiredCount, "->", strings.Trim(fmt.Sprint(targets), "[]"))
+	if dryRun != dryRunNone {
+		fmt.Println("Not scaling down ECS service", service, "in a dry run")
+		return nil
+	}
+
+	awaitDeployment(d)
+
+	kubeConfig, err := root.KubeConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+
+	// awaitDeployment does not wait for a deployment that was never applied, nothing would take over the traffic
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get deployment %s in namespace %s: %v", deploymentName, namespace, err)
+	}
+	if !deploymentAvailable(deployment) {
+		return fmt.Errorf("deployment %s is not available, not scaling down ECS service %s", deploymentName, service)
+	}
+
+	if !assumeYes {
+		fmt.Print("Proceed with scaling down ECS service ", service, " (yes/no): ")
+		confirmed, err := askForConfirmation()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Operation cancelled by user")
+			return nil
+		}
+	}
+
+	if err := saveCutoverRecord(record); err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := updateDesiredCount(cluster, service, target); err != nil {
+			return err
+		}
+		fmt.Println("ECS service", service, "desired count set to", target)
+
+		time.Sleep(cutoverPause)
+
+		// Health gate, the deployment has to cope with the traffic the ECS tasks no longer take
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+		if err != nil || !deploymentAvailable(deployment) {
+			fmt.Println("Deployment", deploymentName, "is no longer available, restoring ECS service", service)
+			if err := restoreService(cluster, service); err != nil {
+				return err
+			}
+			return fmt.Errorf("cutover of ECS service %s reverted", service)
+		}
+	}
+
+	fmt.Println("ECS service", service, "cut over, restore it with: ecs2k8s ecs cutover --cluster", cluster, "--service", service, "--restore")
+	return nil
+}
+
+// Sets the desired count of the service back to the one recorded before the cutover
+func restoreService(cluster string, service string) error {
+	record, err := loadCutoverRecord(cluster, service)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("no cutover of ECS service %s recorded in %s", service, filepath.Dir(cutoverPath(cluster, service)))
+	}
+
+	if dryRun != dryRunNone {
+		fmt.Println("Would restore ECS service", service, "desired count to", record.DesiredCount, "(dry run)")
+		return nil
+	}
+
+	if err := updateDesiredCount(cluster, service, record.DesiredCount); err != nil {
+		return err
+	}
+	if err := os.Remove(cutoverPath(cluster, service)); err != nil {
+		fmt.Println("Unable to remove", cutoverPath(cluster, service)+":", err)
+	}
+	fmt.Println("ECS service", service, "desired count restored to", record.DesiredCount)
+	return nil
+}
+
+func updateDesiredCount(cluster string, service string, desiredCount int32) error {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	client := ecs.NewFromConfig(cfg)
+
+	_, err = client.UpdateService(context.TODO(), &ecs.UpdateServiceInput{
+		Cluster:      &cluster,
+		Service:      &service,
+		DesiredCount: &desiredCount,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update ECS service %s: %v", service, err)
+	}
+	return nil
+}
+
+// Returns nil when no cutover of the service is recorded
+func loadCutoverRecord(cluster string, service string) (*cutoverRecord, error) {
+	bytes, err := ioutil.ReadFile(cutoverPath(cluster, service))
+	if err != nil {
+		return nil, nil
+	}
+	var record cutoverRecord
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", cutoverPath(cluster, service), err)
+	}
+	return &record, nil
+}
+
+func saveCutoverRecord(record *cutoverRecord) error {
+	path := cutoverPath(record.Cluster, record.Service)
+	if err := createSpecDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	bytes, _ := json.MarshalIndent(record, "", "  ")
+	return writeSpecFile(path, bytes)
+}
+
+// Name of a cluster ARN, arn:aws:ecs:region:account:cluster/name
+func clusterName(clusterArn string) string {
+	return clusterArn[strings.LastIndex(clusterArn, "/")+1:]
+}
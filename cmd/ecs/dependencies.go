@@ -0,0 +1,179 @@
+package ecsCmd
+
+import (
+	"fmt"
+	"strings"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// How the containers of a task are started, derived from their dependsOn conditions
+type containerPlan struct {
+	// Containers others wait for, in the order the kubelet starts them
+	initContainers []string
+	// Init containers that keep running alongside the others, mapped to whether they are waited for until healthy
+	sidecars map[string]bool
+	// The containers nothing waits for
+	containers []string
+	// Dependencies that cannot be expressed as is
+	problems []string
+}
+
+// Plans the containers from their dependencies. COMPLETE and SUCCESS dependencies become init containers, START and
+// HEALTHY dependencies become native sidecars, init containers with restartPolicy Always. The kubelet starts the init
+// containers one after the other, the next one once a sidecar has started and its startup probe succeeded.
+func planContainers(defs []types.ContainerDefinition) containerPlan {
+	plan := containerPlan{sidecars: make(map[string]bool)}
+	addSidecar := func(name string, healthy bool) {
+		plan.sidecars[name] = plan.sidecars[name] || healthy
+	}
+
+	defsByName := make(map[string]types.ContainerDefinition)
+	for _, def := range defs {
+		defsByName[*def.Name] = def
+	}
+
+	completion := make(map[string]bool)
+	deps := make(map[string][]string)
+	for _, def := range defs {
+		for _, dep := range def.DependsOn {
+			target, found := defsByName[*dep.ContainerName]
+			if !found {
+				plan.problems = append(plan.problems, fmt.Sprintf("Container %s depends on unknown container %s", *def.Name, *dep.ContainerName))
+				continue
+			}
+			deps[*def.Name] = append(deps[*def.Name], *dep.ContainerName)
+
+			switch dep.Condition {
+			case types.ContainerConditionComplete:
+				completion[*dep.ContainerName] = true
+				plan.problems = append(plan.problems, fmt.Sprintf("Container %s waits for %s to complete, as an init container it has to succeed", *def.Name, *dep.ContainerName))
+			case types.ContainerConditionSuccess:
+				completion[*dep.ContainerName] = true
+			case types.ContainerConditionHealthy:
+				if target.HealthCheck == nil || len(target.HealthCheck.Command) < 2 {
+					plan.problems = append(plan.problems, fmt.Sprintf("Container %s waits for %s to be healthy, which has no health check", *def.Name, *dep.ContainerName))
+					addSidecar(*dep.ContainerName, false)
+					continue
+				}
+				addSidecar(*dep.ContainerName, true)
+			default:
+				addSidecar(*dep.ContainerName, false)
+			}
+		}
+	}
+
+	for name := range plan.sidecars {
+		if completion[name] {
+			plan.problems = append(plan.problems, fmt.Sprintf("Container %s is waited for both to start and to complete, it runs as an init container", name))
+			delete(plan.sidecars, name)
+		}
+	}
+
+	var initNames []string
+	for _, def := range defs {
+		name := *def.Name
+		if _, sidecar := plan.sidecars[name]; sidecar || completion[name] {
+			initNames = append(initNames, name)
+		} else {
+			plan.containers = append(plan.containers, name)
+		}
+	}
+
+	var cycle bool
+	if plan.initContainers, cycle = orderByDependencies(initNames, deps); cycle {
+		plan.problems = append(plan.problems, "Containers "+strings.Join(initNames, ", ")+" depend on each other in a cycle")
+	}
+
+	return plan
+}
+
+// Orders the names so dependencies come first, keeping the original order otherwise. Names left in a cycle are
+// appended in their original order.
+func orderByDependencies(names []string, deps map[string][]string) ([]string, bool) {
+	var ordered []string
+	placed := make(map[string]bool)
+	included := make(map[string]bool)
+	for _, name := range names {
+		included[name] = true
+	}
+
+	for len(ordered) < len(names) {
+		progress := false
+		for _, name := range names {
+			if placed[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[name] {
+				if included[dep] && !placed[dep] {
+					ready = false
+				}
+			}
+			if ready {
+				ordered = append(ordered, name)
+				placed[name] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			for _, name := range names {
+				if !placed[name] {
+					ordered = append(ordered, name)
+				}
+			}
+			return ordered, true
+		}
+	}
+
+	return ordered, false
+}
+
+// Splits the containers into init containers, sidecars included, and the other containers
+func arrangeContainers(containers []corev1.Container, defs []types.ContainerDefinition) ([]corev1.Container, []corev1.Container) {
+	plan := planContainers(defs)
+	for _, problem := range plan.problems {
+		root.PrintWarning(problem)
+	}
+
+	byName := make(map[string]corev1.Container)
+	for _, c := range containers {
+		byName[c.Name] = c
+	}
+
+	var initContainers []corev1.Container
+	for _, name := range plan.initContainers {
+		c := byName[name]
+		healthy, sidecar := plan.sidecars[name]
+		if !sidecar {
+			// Init containers that run to completion cannot have probes
+			c.LivenessProbe, c.ReadinessProbe, c.StartupProbe = nil, nil, nil
+			initContainers = append(initContainers, c)
+			continue
+		}
+
+		always := corev1.ContainerRestartPolicyAlways
+		c.RestartPolicy = &always
+		if healthy {
+			// The next containers start once the startup probe of the sidecar succeeds
+			if c.StartupProbe == nil && c.LivenessProbe != nil {
+				c.StartupProbe = c.LivenessProbe.DeepCopy()
+			}
+		} else {
+			// The next containers start as soon as the sidecar has started
+			c.StartupProbe = nil
+		}
+		initContainers = append(initContainers, c)
+	}
+
+	var ordered []corev1.Container
+	for _, name := range plan.containers {
+		ordered = append(ordered, byName[name])
+	}
+
+	return initContainers, ordered
+}
@@ -0,0 +1,134 @@
+package ecsCmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+func TestPlanContainers(t *testing.T) {
+	container := func(name string, dependsOn ...types.ContainerDependency) types.ContainerDefinition {
+		return types.ContainerDefinition{Name: aws.String(name), DependsOn: dependsOn}
+	}
+	healthChecked := func(name string, dependsOn ...types.ContainerDependency) types.ContainerDefinition {
+		c := container(name, dependsOn...)
+		c.HealthCheck = &types.HealthCheck{Command: []string{"CMD-SHELL", "true"}}
+		return c
+	}
+	dependency := func(name string, condition types.ContainerCondition) types.ContainerDependency {
+		return types.ContainerDependency{ContainerName: aws.String(name), Condition: condition}
+	}
+
+	tests := []struct {
+		name           string
+		defs           []types.ContainerDefinition
+		initContainers []string
+		sidecars       map[string]bool
+		containers     []string
+		problems       int
+	}{
+		{
+			name:       "no dependencies",
+			defs:       []types.ContainerDefinition{container("app"), container("log")},
+			sidecars:   map[string]bool{},
+			containers: []string{"app", "log"},
+		},
+		{
+			name: "init containers in dependency order",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("migrate", types.ContainerConditionSuccess)),
+				container("migrate", dependency("fetch", types.ContainerConditionSuccess)),
+				container("fetch"),
+			},
+			initContainers: []string{"fetch", "migrate"},
+			sidecars:       map[string]bool{},
+			containers:     []string{"app"},
+		},
+		{
+			name: "sidecars",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("proxy", types.ContainerConditionHealthy), dependency("log", types.ContainerConditionStart)),
+				healthChecked("proxy", dependency("log", types.ContainerConditionStart)),
+				container("log"),
+			},
+			initContainers: []string{"log", "proxy"},
+			sidecars:       map[string]bool{"log": false, "proxy": true},
+			containers:     []string{"app"},
+		},
+		{
+			name: "complete has to succeed",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("setup", types.ContainerConditionComplete)),
+				container("setup"),
+			},
+			initContainers: []string{"setup"},
+			sidecars:       map[string]bool{},
+			containers:     []string{"app"},
+			problems:       1,
+		},
+		{
+			name: "healthy without health check",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("proxy", types.ContainerConditionHealthy)),
+				container("proxy"),
+			},
+			initContainers: []string{"proxy"},
+			sidecars:       map[string]bool{"proxy": false},
+			containers:     []string{"app"},
+			problems:       1,
+		},
+		{
+			name: "waited for to start and to complete",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("setup", types.ContainerConditionSuccess)),
+				container("worker", dependency("setup", types.ContainerConditionStart)),
+				container("setup"),
+			},
+			initContainers: []string{"setup"},
+			sidecars:       map[string]bool{},
+			containers:     []string{"app", "worker"},
+			problems:       1,
+		},
+		{
+			name: "unknown container",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("db", types.ContainerConditionStart)),
+			},
+			sidecars:   map[string]bool{},
+			containers: []string{"app"},
+			problems:   1,
+		},
+		{
+			name: "cycle",
+			defs: []types.ContainerDefinition{
+				container("app", dependency("a", types.ContainerConditionStart)),
+				container("a", dependency("b", types.ContainerConditionStart)),
+				container("b", dependency("a", types.ContainerConditionStart)),
+			},
+			initContainers: []string{"a", "b"},
+			sidecars:       map[string]bool{"a": false, "b": false},
+			containers:     []string{"app"},
+			problems:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planContainers(tt.defs)
+			if !reflect.DeepEqual(plan.initContainers, tt.initContainers) {
+				t.Errorf("init containers = %v, want %v", plan.initContainers, tt.initContainers)
+			}
+			if !reflect.DeepEqual(plan.sidecars, tt.sidecars) {
+				t.Errorf("sidecars = %v, want %v", plan.sidecars, tt.sidecars)
+			}
+			if !reflect.DeepEqual(plan.containers, tt.containers) {
+				t.Errorf("containers = %v, want %v", plan.containers, tt.containers)
+			}
+			if len(plan.problems) != tt.problems {
+				t.Errorf("problems = %q, want %d", plan.problems, tt.problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package ecsCmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// Fields populated by the API server, left out of the diff
+var serverPopulatedFields = [][]string{
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+	{"status"},
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what migrating a task definition would change in the K8s cluster",
+	Long: `Show what migrating a task definition would change in the K8s cluster. The generated objects are compared with
+the live objects after a server-side dry run, secret values are masked. Exits with 1 when there are changes and with 2 on errors. For example:
+
+	ecs2k8s ecs diff --task-definition xxxx --namespace xxxx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Like kubectl diff, 1 means there are changes and errors exit with 2
+		drift, err := runDiff(cmd)
+		if err != nil {
+			return &root.ExitError{Code: 2, Err: err}
+		}
+		if drift {
+			return &root.ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(diffCmd)
+	diffCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &root.ExitError{Code: 2, Err: err}
+	})
+}
+
+// Prints the changes migrating the task definitions would make, returns true if there are any
+func runDiff(cmd *cobra.Command) (bool, error) {
+	taskDefinition, _ := cmd.Flags().GetString("task-definition")
+	fromFile, _ := cmd.Flags().GetString("from-file")
+	service, _ := cmd.Flags().GetString("service")
+	cluster, _ := cmd.Flags().GetString("cluster")
+	rCount, _ := cmd.Flags().GetInt32("replicas")
+	namespace, _ := cmd.Flags().GetString("namespace")
+
+	if taskDefinition == "" && fromFile == "" && service == "" {
+		return false, errors.New("task definition, --from-file or --service required")
+	}
+
+	if service != "" && cluster == "" {
+		return false, errors.New("cluster required when converting a service")
+	}
+
+	if namespace == "" {
+		return false, errors.New("namespace required")
+	}
+
+	if err := readConversionFlags(cmd); err != nil {
+		return false, err
+	}
+	root.ReadKubeFlags(cmd)
+
+	var tds []ecs.DescribeTaskDefinitionOutput
+	if service != "" {
+		svc, td, err := loadService(cluster, service)
+		if err != nil {
+			return false, err
+		}
+		if cmd.Flags().Changed("replicas") {
+			svc.DesiredCount = rCount
+		}
+		ecsService = &svc
+		tds = append(tds, td)
+	} else {
+		var err error
+		if tds, err = loadTaskDefinitions(taskDefinition, fromFile); err != nil {
+			return false, err
+		}
+	}
+
+	config, err := root.KubeConfig()
+	if err != nil {
+		return false, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return false, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return false, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	drift := false
+	for _, td := range tds {
+		resetGeneratedObjects()
+		d, err := generateDeploymentObject(td, rCount, namespace, false)
+		if err != nil {
+			return false, err
+		}
+		for _, obj := range append([]runtime.Object{&d}, generatedObjects()...) {
+			changed, err := diffKubeObject(client, mapper, obj)
+			if err != nil {
+				return false, err
+			}
+			drift = drift || changed
+		}
+	}
+	return drift, nil
+}
+
+// Prints the changes applying the object would make to the live object, returns true if there are any
+func diffKubeObject(client dynamic.Interface, mapper meta.RESTMapper, obj runtime.Object) (bool, error) {
+	u, err := applyConfiguration(obj)
+	if err != nil {
+		return false, err
+	}
+	gvk := u.GroupVersionKind()
+	name := u.GetName()
+	if u.GetNamespace() != "" {
+		name = u.GetNamespace() + "/" + name
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		fmt.Println("+", gvk.Kind, name, "(new, the", gvk.GroupVersion().String(), "API is not installed in the cluster)")
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to find the %s API: %v", gvk.GroupVersion().String(), err)
+	}
+
+	var ri dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ri = client.Resource(mapping.Resource).Namespace(u.GetNamespace())
+	}
+
+	live, err := ri.Get(context.TODO(), u.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		fmt.Println("+", gvk.Kind, name, "(new)")
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get %s %s: %v", gvk.Kind, name, err)
+	}
+
+	// The object as it would be after applying, with the defaults and the fields of other managers
+	data, _ := json.Marshal(u)
+	force := true
+	merged, err := ri.Patch(context.TODO(), u.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        &force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to dry run %s %s: %v", gvk.Kind, name, err)
+	}
+
+	before, after := live.DeepCopy(), merged.DeepCopy()
+	for _, fields := range serverPopulatedFields {
+		unstructured.RemoveNestedField(before.Object, fields...)
+		unstructured.RemoveNestedField(after.Object, fields...)
+	}
+	if gvk.Kind == "Secret" {
+		maskSecretData(before, after)
+	}
+
+	var changes []string
+	diffValues("", before.Object, after.Object, &changes)
+	if len(changes) == 0 {
+		fmt.Println("=", gvk.Kind, name, "(unchanged)")
+		return false, nil
+	}
+
+	fmt.Println("~", gvk.Kind, name)
+	for _, change := range changes {
+		fmt.Println("   ", change)
+	}
+	return true, nil
+}
+
+// Replaces secret values with *** so the diff only shows which keys change
+func maskSecretData(before *unstructured.Unstructured, after *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		b, _, _ := unstructured.NestedMap(before.Object, field)
+		a, _, _ := unstructured.NestedMap(after.Object, field)
+		for k, v := range b {
+			if av, ok := a[k]; ok {
+				if reflect.DeepEqual(v, av) {
+					b[k], a[k] = "***", "***"
+				} else {
+					b[k], a[k] = "*** (before)", "*** (after)"
+				}
+			} else {
+				b[k] = "***"
+			}
+		}
+		for k := range a {
+			if _, ok := b[k]; !ok {
+				a[k] = "***"
+			}
+		}
+		if b != nil {
+			_ = unstructured.SetNestedMap(before.Object, b, field)
+		}
+		if a != nil {
+			_ = unstructured.SetNestedMap(after.Object, a, field)
+		}
+	}
+}
+
+// Collects the changed fields between two objects as +, - and ~ lines, list items with a name are matched by name
+func diffValues(path string, before interface{}, after interface{}, changes *[]string) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range b {
+			keys[k] = true
+		}
+		for k := range a {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			bv, bok := b[k]
+			av, aok := a[k]
+			p := joinPath(path, k)
+			switch {
+			case !bok:
+				*changes = append(*changes, "+ "+p+": "+formatValue(av))
+			case !aok:
+				*changes = append(*changes, "- "+p+": "+formatValue(bv))
+			default:
+				diffValues(p, bv, av, changes)
+			}
+		}
+		return
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		if names(b) != nil && names(a) != nil {
+			diffValues(path, namedItems(b), namedItems(a), changes)
+			return
+		}
+		for i := 0; i < len(b) || i < len(a); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(b):
+				*changes = append(*changes, "+ "+p+": "+formatValue(a[i]))
+			case i >= len(a):
+				*changes = append(*changes, "- "+p+": "+formatValue(b[i]))
+			default:
+				diffValues(p, b[i], a[i], changes)
+			}
+		}
+		return
+	}
+
+	*changes = append(*changes, "~ "+path+": "+formatValue(before)+" -> "+formatValue(after))
+}
+
+// Names of the list items, nil if any item has no name
+func names(items []interface{}) []string {
+	result := []string{}
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// Keys list items by name, so containers, env vars and ports are compared by name rather than position
+func namedItems(items []interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, item := range items {
+		result["["+item.(map[string]interface{})["name"].(string)+"]"] = item
+	}
+	return result
+}
+
+func joinPath(path string, key string) string {
+	if path == "" || (len(key) > 0 && key[0] == '[') {
+		return path + key
+	}
+	return path + "." + key
+}
+
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
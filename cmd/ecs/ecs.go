@@ -17,6 +17,7 @@ package ecsCmd
 
 import (
 	"os"
+	"time"
 
 	root "codaglobal/ecs2k8s/cmd/root"
 
@@ -36,12 +37,71 @@ var ecsCmd = &cobra.Command{
 
 func init() {
 	root.RootCmd.AddCommand(ecsCmd)
-	ecsCmd.PersistentFlags().String("task-definition", "", "A valid task definition in ECS")
+	ecsCmd.PersistentFlags().String("task-definition", "", "A valid task definition in ECS, several revisions or families can be comma separated")
+	ecsCmd.PersistentFlags().String("service", "", "A valid service in the ECS cluster, replicas, rolling update strategy and task definition are taken from it")
+	ecsCmd.PersistentFlags().String("cluster", "", "The ECS cluster the service runs in")
+	ecsCmd.PersistentFlags().String("from-file", "", "Read task definitions from a JSON file, a directory of JSON files or stdin (-) instead of ECS")
+	ecsCmd.PersistentFlags().Bool("all", false, "Convert every active task family, or every service of --cluster, into a directory per family named after --file-name")
+	ecsCmd.PersistentFlags().String("include", "", "With --all, comma separated glob patterns of the task families or services to convert")
+	ecsCmd.PersistentFlags().String("exclude", "", "With --all, comma separated glob patterns of the task families or services to skip")
+	ecsCmd.PersistentFlags().String("tag-filter", "", "With --all, comma separated key=value tags the task definitions or services must have, a key alone matches any value")
+	ecsCmd.PersistentFlags().Int32("workers", 4, "With --all, the number of task families converted concurrently")
 	ecsCmd.PersistentFlags().String("container-name", "", "Name of the container inside the task, if more than one container is specified in that task")
 	ecsCmd.PersistentFlags().StringP("namespace", "n", "", "The Kubernetes namespace in which the deployment needs to be created")
-	ecsCmd.PersistentFlags().String("file-name", "", "The file into which K8s spec will be written to, defaults to datetime of spec generation")
+	ecsCmd.PersistentFlags().String("file-name", "", "The file into which K8s spec will be written to, defaults to datetime of spec generation, - writes to stdout")
+	ecsCmd.PersistentFlags().String("layout", "list", "How the generated objects are written, a v1 List (list), multi-document YAML (documents) or a directory with one file per object (files)")
 	ecsCmd.PersistentFlags().Bool("yaml", false, "Set this flag if spec file needs to generated in YAML, defaults to JSON")
-	ecsCmd.PersistentFlags().String("kubeconfig", "", "Config file for the K8s cluster, if parameter is not passed, checks $HOME/.kube directory and then KUBECONFIG environment variable")
+	ecsCmd.PersistentFlags().String("output-format", outputFormatJSON, "Format of the generated spec, json, yaml, helm (a chart directory named after --file-name) or kustomize (a base and overlays directory named after --file-name)")
+	ecsCmd.PersistentFlags().String("overlays", "", "Comma separated names of the kustomize overlays generated for the task definitions after the first one of a family, defaults to revision-<n>")
+	root.AddKubeFlags(ecsCmd)
+	ecsCmd.PersistentFlags().BoolP("yes", "y", false, "Apply the K8s objects without prompting for confirmation")
+	ecsCmd.PersistentFlags().String("dry-run", "none", "none, client to print the K8s objects instead of applying them, or server to validate them with the API server without persisting them")
+	ecsCmd.PersistentFlags().Lookup("dry-run").NoOptDefVal = "client"
+	ecsCmd.PersistentFlags().Bool("force-conflicts", false, "Take over the fields of existing K8s objects that are managed by another field manager")
+	ecsCmd.PersistentFlags().Bool("wait", false, "Wait for the migrated deployment to become available, reporting pod events and container restarts, exits with 1 if it does not")
+	ecsCmd.PersistentFlags().Duration("timeout", 5*time.Minute, "How long --wait waits for the deployment to become available")
+	ecsCmd.PersistentFlags().Bool("cutover", false, "With migrate-service, scale the ECS service down once the deployment is available, see the cutover command")
+	ecsCmd.PersistentFlags().Int32("cutover-steps", 4, "Number of steps the ECS service desired count is reduced to 0 in during a cutover")
+	ecsCmd.PersistentFlags().Duration("cutover-pause", time.Minute, "Pause after each cutover step before checking that the deployment is still available")
+	ecsCmd.PersistentFlags().Bool("rollback-on-failure", true, "Delete the K8s objects created and restore the ones updated by migrate-task or migrate-service when the migration fails")
 	ecsCmd.PersistentFlags().Int32("replicas", 1, "The replica count for the K8s deployment")
+	ecsCmd.PersistentFlags().String("iam-mode", iamModeIRSA, "How the task IAM role is carried over to the ServiceAccount, irsa, pod-identity or none")
+	ecsCmd.PersistentFlags().String("eks-cluster", "", "The EKS cluster to associate the ServiceAccount with when using --iam-mode pod-identity")
+	ecsCmd.PersistentFlags().String("oidc-provider", "", "The OIDC provider of the EKS cluster used in the trust policy report, e.g. oidc.eks.us-east-1.amazonaws.com/id/XXXX")
+	ecsCmd.PersistentFlags().Bool("trust-policy-report", false, "Print the trust policy statement each task role needs to be assumed from the K8s cluster")
+	ecsCmd.PersistentFlags().Int32("progress-deadline", 600, "Progress deadline in seconds for services with the ECS deployment circuit breaker enabled")
 	ecsCmd.PersistentFlags().Bool("include-secrets", false, "Set this flag to exclude secrets being created in Kubernetes")
+	ecsCmd.PersistentFlags().String("secrets-mode", secretsModeCopy, "How secrets are carried over, copy their values into K8s secrets, generate External Secrets Operator resources (external-secrets) or mount them with the Secrets Store CSI driver (csi)")
+	ecsCmd.PersistentFlags().Bool("sync-secrets", false, "With --secrets-mode csi, sync the mounted secrets to K8s secrets so they can be used as environment variables")
+	ecsCmd.PersistentFlags().String("secret-store-kind", "SecretStore", "Kind of the External Secrets Operator store to generate, SecretStore or ClusterSecretStore")
+	ecsCmd.PersistentFlags().String("bind-mount-type", "hostPath", "How bind mounts with a host source path are translated, hostPath or emptyDir")
+	ecsCmd.PersistentFlags().Bool("http-probes", false, "Emit HTTP/TCP probes for health checks that curl, wget or nc a mapped port, instead of exec probes")
+}
+
+// Reads the flags shared by the commands that convert task definitions
+func readConversionFlags(cmd *cobra.Command) error {
+	includeSecrets, _ = cmd.Flags().GetBool("include-secrets")
+	httpProbes, _ = cmd.Flags().GetBool("http-probes")
+	bindMountType, _ = cmd.Flags().GetString("bind-mount-type")
+	progressDeadline, _ = cmd.Flags().GetInt32("progress-deadline")
+	secretsMode, _ = cmd.Flags().GetString("secrets-mode")
+	secretStoreKind, _ = cmd.Flags().GetString("secret-store-kind")
+	syncCSISecrets, _ = cmd.Flags().GetBool("sync-secrets")
+	iamMode, _ = cmd.Flags().GetString("iam-mode")
+	eksCluster, _ = cmd.Flags().GetString("eks-cluster")
+	oidcProvider, _ = cmd.Flags().GetString("oidc-provider")
+	trustPolicyReport, _ = cmd.Flags().GetBool("trust-policy-report")
+
+	// Only copying secret values needs to be opted into
+	if secretsMode != secretsModeCopy {
+		includeSecrets = true
+	}
+
+	if err := validateBindMountType(); err != nil {
+		return err
+	}
+	if err := validateSecretsMode(); err != nil {
+		return err
+	}
+	return validateIAMMode()
 }
@@ -0,0 +1,174 @@
+package ecsCmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	secretsModeCopy            = "copy"
+	secretsModeExternalSecrets = "external-secrets"
+)
+
+var (
+	externalSecretsGroupVersion = schema.GroupVersion{Group: "external-secrets.io", Version: "v1beta1"}
+	externalSecretResource      = externalSecretsGroupVersion.WithResource("externalsecrets")
+	secretStoreResource         = externalSecretsGroupVersion.WithResource("secretstores")
+	clusterSecretStoreResource  = externalSecretsGroupVersion.WithResource("clustersecretstores")
+)
+
+// How ECS secrets are carried over, "copy", "external-secrets" or "csi"
+var secretsMode string
+
+// Kind of the External Secrets Operator store, "SecretStore" or "ClusterSecretStore"
+var secretStoreKind string
+
+var secretStores []unstructured.Unstructured
+
+var externalSecrets []unstructured.Unstructured
+
+var awsDefaultRegion string
+
+func validateSecretsMode() error {
+	switch secretsMode {
+	case secretsModeCopy, secretsModeCSI:
+	case secretsModeExternalSecrets:
+		if secretStoreKind != "SecretStore" && secretStoreKind != "ClusterSecretStore" {
+			return fmt.Errorf("invalid secret store kind %s - must be SecretStore or ClusterSecretStore", secretStoreKind)
+		}
+	default:
+		return fmt.Errorf("invalid secrets mode %s - must be %s, %s or %s", secretsMode, secretsModeCopy, secretsModeExternalSecrets, secretsModeCSI)
+	}
+	return nil
+}
+
+// Region of secrets referenced by name, read from the AWS config of the user
+func getDefaultRegion() (string, error) {
+	if awsDefaultRegion == "" {
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return "", err
+		}
+		awsDefaultRegion = cfg.Region
+	}
+	return awsDefaultRegion, nil
+}
+
+// Adds the reference to the ExternalSecret of its K8s secret, generating the secret store it reads from
+func generateExternalSecret(ref secretReference, namespace string) error {
+	region := ref.region
+	if region == "" {
+		var err error
+		if region, err = getDefaultRegion(); err != nil {
+			return err
+		}
+	}
+
+	storeName := generateSecretStore(ref.service, region, namespace)
+
+	remoteRef := map[string]interface{}{
+		"key": ref.secretId,
+	}
+	if ref.jsonKey != "" {
+		remoteRef["property"] = ref.jsonKey
+	}
+	// Version IDs are prefixed with uuid/, anything else is treated as a version stage
+	if ref.versionId != "" {
+		remoteRef["version"] = "uuid/" + ref.versionId
+	} else if ref.versionStage != "" {
+		remoteRef["version"] = ref.versionStage
+	}
+
+	data := map[string]interface{}{
+		"secretKey": ref.secretKey,
+		"remoteRef": remoteRef,
+	}
+
+	for i := range externalSecrets {
+		if externalSecrets[i].GetName() != ref.secretName {
+			continue
+		}
+		entries, _, _ := unstructured.NestedSlice(externalSecrets[i].Object, "spec", "data")
+		for _, entry := range entries {
+			if entry.(map[string]interface{})["secretKey"] == ref.secretKey {
+				return nil
+			}
+		}
+		// Keys from another service or region are read through their own store
+		if name, _, _ := unstructured.NestedString(externalSecrets[i].Object, "spec", "secretStoreRef", "name"); name != storeName {
+			data["sourceRef"] = map[string]interface{}{
+				"storeRef": map[string]interface{}{
+					"name": storeName,
+					"kind": secretStoreKind,
+				},
+			}
+		}
+		_ = unstructured.SetNestedSlice(externalSecrets[i].Object, append(entries, data), "spec", "data")
+		return nil
+	}
+
+	externalSecret := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"refreshInterval": "1h",
+				"secretStoreRef": map[string]interface{}{
+					"name": storeName,
+					"kind": secretStoreKind,
+				},
+				"target": map[string]interface{}{
+					"name":           ref.secretName,
+					"creationPolicy": "Owner",
+				},
+				"data": []interface{}{data},
+			},
+		},
+	}
+	externalSecret.SetAPIVersion(externalSecretsGroupVersion.String())
+	externalSecret.SetKind("ExternalSecret")
+	externalSecret.SetName(ref.secretName)
+	externalSecret.SetNamespace(namespace)
+
+	externalSecrets = append(externalSecrets, externalSecret)
+	return nil
+}
+
+// Generates a store for the AWS service and region, stores authenticate with the controller's own IAM role
+func generateSecretStore(service string, region string, namespace string) string {
+	awsService := "SecretsManager"
+	if service == "ssm" {
+		awsService = "ParameterStore"
+	}
+	name := kubeName("aws-" + service + "-" + region)
+
+	for i := range secretStores {
+		if secretStores[i].GetName() == name {
+			return name
+		}
+	}
+
+	store := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"provider": map[string]interface{}{
+					"aws": map[string]interface{}{
+						"service": awsService,
+						"region":  region,
+					},
+				},
+			},
+		},
+	}
+	store.SetAPIVersion(externalSecretsGroupVersion.String())
+	store.SetKind(secretStoreKind)
+	store.SetName(name)
+	if secretStoreKind == "SecretStore" {
+		store.SetNamespace(namespace)
+	}
+
+	secretStores = append(secretStores, store)
+	return name
+}
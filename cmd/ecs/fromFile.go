@@ -0,0 +1,107 @@
+package ecsCmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// taskDefinitionFile matches the output of `aws ecs describe-task-definition`.
+// The bare `register-task-definition` input shape is handled as a fallback.
+type taskDefinitionFile struct {
+	TaskDefinition *taskDefinitionDocument
+	Tags           []types.Tag
+}
+
+// A task definition with the fields set by ECS on registration left undecoded, the AWS CLI v1 prints the timestamps
+// as epoch seconds rather than as the RFC 3339 strings time.Time expects, and the conversion does not use them
+type taskDefinitionDocument struct {
+	types.TaskDefinition
+	RegisteredAt   json.RawMessage
+	DeregisteredAt json.RawMessage
+	RegisteredBy   json.RawMessage
+}
+
+// Reads task definitions from a JSON file, a directory of JSON files or stdin ("-")
+func readTaskDefinitionsFromFile(path string) ([]ecs.DescribeTaskDefinitionOutput, error) {
+	var outputs []ecs.DescribeTaskDefinitionOutput
+
+	if path == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read task definition from stdin: %v", err)
+		}
+		output, err := parseTaskDefinitionJSON(data, "stdin")
+		if err != nil {
+			return nil, err
+		}
+		return append(outputs, output), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("no valid task definition file found in the specified location, %s", path)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*.json"))
+		sort.Strings(files)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no task definition JSON files found in %s", path)
+		}
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read task definition file %s: %v", file, err)
+		}
+		fmt.Println("Reading task definition from", file)
+		output, err := parseTaskDefinitionJSON(data, file)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+
+	return outputs, nil
+}
+
+// Parses a single task definition document into the shape returned by DescribeTaskDefinition
+func parseTaskDefinitionJSON(data []byte, source string) (ecs.DescribeTaskDefinitionOutput, error) {
+	var file taskDefinitionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ecs.DescribeTaskDefinitionOutput{}, fmt.Errorf("invalid task definition JSON in %s: %v", source, err)
+	}
+
+	// Not wrapped in "taskDefinition", so treat it as register-task-definition input
+	if file.TaskDefinition == nil {
+		var td taskDefinitionDocument
+		if err := json.Unmarshal(data, &td); err != nil {
+			return ecs.DescribeTaskDefinitionOutput{}, fmt.Errorf("invalid task definition JSON in %s: %v", source, err)
+		}
+		file.TaskDefinition = &td
+	}
+
+	td := &file.TaskDefinition.TaskDefinition
+	if td.Family == nil || strings.TrimSpace(*td.Family) == "" {
+		return ecs.DescribeTaskDefinitionOutput{}, fmt.Errorf("task definition in %s has no family", source)
+	}
+
+	if len(td.ContainerDefinitions) == 0 {
+		return ecs.DescribeTaskDefinitionOutput{}, fmt.Errorf("task definition in %s has no container definitions", source)
+	}
+
+	return ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: td,
+		Tags:           file.Tags,
+	}, nil
+}
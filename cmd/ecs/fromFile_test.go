@@ -0,0 +1,35 @@
+package ecsCmd
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseTaskDefinitionJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		containers int
+		tags       int
+	}{
+		{"describe output of the AWS CLI v1 with epoch timestamps", "testdata/describe-task-definition-cli-v1.json", 1, 1},
+		{"describe output of the AWS CLI v2", "testdata/describe-task-definition-cli-v2.json", 1, 1},
+		{"register input", "testdata/register-task-definition.json", 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := parseTaskDefinitionJSON(data, tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *output.TaskDefinition.Family != "web" || len(output.TaskDefinition.ContainerDefinitions) != tt.containers || len(output.Tags) != tt.tags {
+				t.Errorf("parseTaskDefinitionJSON(%s) = %+v", tt.file, output)
+			}
+		})
+	}
+}
@@ -19,15 +19,18 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
+	root "codaglobal/ecs2k8s/cmd/root"
+
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/spf13/cobra"
 
 	"github.com/aws/aws-secretsmanager-caching-go/secretcache"
@@ -44,58 +47,147 @@ var secrets []corev1.Secret
 
 var includeSecrets bool
 
+const (
+	specLayoutList      = "list"
+	specLayoutDocuments = "documents"
+	specLayoutFiles     = "files"
+)
+
+// How the generated objects are written, "list", "documents" or "files"
+var specLayout string
+
+// Stdout the spec is written to with --file-name -, os.Stdout is redirected to stderr for progress messages
+var specStdout *os.File
+
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "generate-k8s-spec",
 	Short: "Generate the YAML or Helm charts for the tasks",
 	Long:  `Generate the YAML or Helm charts for the tasks. For example:`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		taskDefintion, _ := cmd.Flags().GetString("task-definition")
+		fromFile, _ := cmd.Flags().GetString("from-file")
+		service, _ := cmd.Flags().GetString("service")
+		cluster, _ := cmd.Flags().GetString("cluster")
 		fileName, _ := cmd.Flags().GetString("file-name")
 		rCount, _ := cmd.Flags().GetInt32("replicas")
 		yaml, _ := cmd.Flags().GetBool("yaml")
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		overlays, _ := cmd.Flags().GetString("overlays")
 		namespace, _ := cmd.Flags().GetString("namespace")
-		includeSecrets, _ = cmd.Flags().GetBool("include-secrets")
+		all, _ := cmd.Flags().GetBool("all")
 
 		if fileName == "" {
 			fileName = getDefaultFileName()
 		}
 
-		if taskDefintion == "" {
-			fmt.Println("Task definition required")
-			os.Exit(1)
+		if all {
+			if taskDefintion != "" || fromFile != "" || service != "" {
+				return errors.New("--all can't be combined with a task definition, --from-file or --service")
+			}
+			if namespace == "" {
+				return errors.New("namespace required")
+			}
+			return generateAll(cmd, cluster, fileName)
 		}
 
-		if namespace == "" {
-			fmt.Println("Namespace required")
-			os.Exit(1)
+		if taskDefintion == "" && fromFile == "" && service == "" {
+			return errors.New("task definition, --from-file or --service required")
+		}
+
+		if service != "" && cluster == "" {
+			return errors.New("cluster required when converting a service")
 		}
 
-		td := getTaskDefiniton(taskDefintion)
-		d := generateDeploymentObject(td, rCount, namespace, false)
+		if namespace == "" {
+			return errors.New("namespace required")
+		}
 
-		if len(secrets) > 0 {
-			var list = corev1.List{
-				TypeMeta: metav1.TypeMeta{
-					Kind:       "List",
-					APIVersion: "v1",
-				},
-				ListMeta: metav1.ListMeta{},
+		specLayout, _ = cmd.Flags().GetString("layout")
+		switch specLayout {
+		case specLayoutList:
+		case specLayoutDocuments:
+			// Multi-document output is YAML only
+			yaml = true
+		case specLayoutFiles:
+			if fileName == root.StdoutFileName {
+				return errors.New("the files layout writes a directory and can't be written to stdout")
 			}
-			var objs = []runtime.Object{}
+		default:
+			return fmt.Errorf("invalid layout %s - must be %s, %s or %s", specLayout, specLayoutList, specLayoutDocuments, specLayoutFiles)
+		}
 
-			objs = append(objs, runtime.Object(&d))
-			for _, secret := range secrets {
-				objs = append(objs, runtime.Object(&secret))
+		switch outputFormat {
+		case outputFormatJSON:
+		case outputFormatYAML:
+			yaml = true
+		case outputFormatHelm, outputFormatKustomize:
+			if fileName == root.StdoutFileName {
+				return fmt.Errorf("the %s output format writes a directory and can't be written to stdout", outputFormat)
 			}
+		default:
+			return fmt.Errorf("invalid output format %s - must be %s, %s, %s or %s", outputFormat, outputFormatJSON, outputFormatYAML, outputFormatHelm, outputFormatKustomize)
+		}
+
+		// Progress messages go to stderr so the spec can be piped into kubectl
+		if fileName == root.StdoutFileName {
+			specStdout = os.Stdout
+			os.Stdout = os.Stderr
+		}
 
-			if err := meta.SetList(&list, objs); err != nil {
-				return
+		if err := readConversionFlags(cmd); err != nil {
+			return err
+		}
+
+		var tds []ecs.DescribeTaskDefinitionOutput
+		if service != "" {
+			svc, td, err := loadService(cluster, service)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("replicas") {
+				svc.DesiredCount = rCount
 			}
-			generateK8sSpecFile(list, fileName, yaml)
+			ecsService = &svc
+			tds = append(tds, td)
 		} else {
-			generateK8sSpecFile(d, fileName, yaml)
+			var err error
+			if tds, err = loadTaskDefinitions(taskDefintion, fromFile); err != nil {
+				return err
+			}
 		}
+
+		var variants []kustomizeVariant
+		for _, td := range tds {
+			resetGeneratedObjects()
+			d, err := generateDeploymentObject(td, rCount, namespace, false)
+			if err != nil {
+				return err
+			}
+
+			// Keep one spec file per task family when several are converted at once
+			specFileName := fileName
+			if len(tds) > 1 && fileName != root.StdoutFileName {
+				specFileName = fileName + "-" + *td.TaskDefinition.Family
+			}
+			switch outputFormat {
+			case outputFormatHelm:
+				err = writeHelmChart(d, td, specFileName)
+			case outputFormatKustomize:
+				// Written once all the revisions of the family are converted
+				variants = append(variants, kustomizeVariant{output: td, objs: append([]runtime.Object{&d}, generatedObjects()...)})
+			default:
+				err = writeK8sSpec(d, specFileName, yaml)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if outputFormat == outputFormatKustomize {
+			return writeKustomizations(variants, fileName, overlays)
+		}
+		return nil
 	},
 }
 
@@ -104,11 +196,11 @@ func init() {
 }
 
 // Fetch Task definition from ECS
-func getTaskDefiniton(taskDefinition string) ecs.DescribeTaskDefinitionOutput {
+func getTaskDefiniton(taskDefinition string) (ecs.DescribeTaskDefinitionOutput, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	fmt.Println("Fetching", taskDefinition, "from ECS...")
 	if err != nil {
-		log.Fatal(err)
+		return ecs.DescribeTaskDefinitionOutput{}, err
 	}
 
 	client := ecs.NewFromConfig(cfg)
@@ -119,29 +211,68 @@ func getTaskDefiniton(taskDefinition string) ecs.DescribeTaskDefinitionOutput {
 	})
 
 	if err != nil {
-		log.Fatal(err)
+		return ecs.DescribeTaskDefinitionOutput{}, err
+	}
+
+	return *output, nil
+}
+
+// Loads task definitions from local JSON when a file is specified, otherwise from ECS
+func loadTaskDefinitions(taskDefinition string, fromFile string) ([]ecs.DescribeTaskDefinitionOutput, error) {
+	if fromFile != "" {
+		return readTaskDefinitionsFromFile(fromFile)
 	}
 
-	return *output
+	// Several revisions or families can be converted at once, e.g. app:3,app:4
+	var tds []ecs.DescribeTaskDefinitionOutput
+	for _, name := range strings.Split(taskDefinition, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			td, err := getTaskDefiniton(name)
+			if err != nil {
+				return nil, err
+			}
+			tds = append(tds, td)
+		}
+	}
+	return tds, nil
 }
 
 // Generate K8s deployment object
-func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount int32, namespace string, apply bool) appsv1.Deployment {
+func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount int32, namespace string, apply bool) (appsv1.Deployment, error) {
 	var kubeContainers []corev1.Container
 	var kubeLabels map[string]string = make(map[string]string)
+	var selectorLabels map[string]string = make(map[string]string)
 
 	// Imports tags to labels
 	for _, object := range output.Tags {
 		key := sanitizeValue(*object.Key, labelSpecialChars, "-")
 		value := sanitizeValue(*object.Value, labelSpecialChars, "-")
 		kubeLabels[key] = value
+		selectorLabels[key] = value
+	}
+
+	// Label used by Services to select the pods of this task family. The deployment selector is immutable and stays
+	// the tag labels, the name label only selects when there are no tags.
+	if _, tagged := kubeLabels["app.kubernetes.io/name"]; !tagged {
+		kubeLabels["app.kubernetes.io/name"] = kubeName(*output.TaskDefinition.Family)
+	}
+	nameLabels := map[string]string{"app.kubernetes.io/name": kubeLabels["app.kubernetes.io/name"]}
+	if len(selectorLabels) == 0 {
+		selectorLabels = nameLabels
 	}
 
+	// Task IAM role as ServiceAccount for IRSA or EKS Pod Identity
+	serviceAccountName := generateServiceAccount(output, namespace, apply)
+
+	// Imports task volumes – bind mounts, Docker volumes, EFS
+	kubeVolumes := generateVolumes(output, namespace, apply)
+
 	// Imports container definition – Name, Image, Port mapping
 	for _, object := range output.TaskDefinition.ContainerDefinitions {
 		// K8s object declarations
 		var containerPorts []corev1.ContainerPort
 		var envVars []corev1.EnvVar
+		var mountSecretsStore bool
 		// ECS object
 		PortMappings := object.PortMappings
 		EnvironmentVars := object.Environment
@@ -150,10 +281,13 @@ func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount in
 		// Port mapping
 		for _, object := range PortMappings {
 			cp := corev1.ContainerPort{
-				HostPort:      *object.HostPort,
 				ContainerPort: *object.ContainerPort,
 				Protocol:      corev1.ProtocolTCP,
 			}
+			// hostPort is optional in task definition JSON (and unused with awsvpc)
+			if object.HostPort != nil {
+				cp.HostPort = *object.HostPort
+			}
 			containerPorts = append(containerPorts, cp)
 		}
 
@@ -166,7 +300,7 @@ func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount in
 			envVars = append(envVars, ev)
 		}
 
-		// ECS Secrets (Secrets Manager) mounted as Environment variables from Kubernetes Secrets
+		// ECS Secrets (Secrets Manager, Parameter Store) mounted as Environment variables from Kubernetes Secrets
 
 		if includeSecrets {
 			// var kubeSecrets []string
@@ -174,18 +308,43 @@ func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount in
 				// secretData := make(map[string][]byte)
 				envVarName := sanitizeValue(*ecsSecret.Name, envSpecialChars, "")
 
-				secretName, secretKey, secretValue := parseSecret(*ecsSecret.ValueFrom)
+				ref, err := parseSecret(*ecsSecret.ValueFrom)
+				if err != nil {
+					return appsv1.Deployment{}, err
+				}
 
-				generateK8sSecret(secretName, secretValue, namespace, apply)
+				switch secretsMode {
+				case secretsModeExternalSecrets:
+					// The External Secrets Operator syncs the values into the K8s secret
+					if err := generateExternalSecret(ref, namespace); err != nil {
+						return appsv1.Deployment{}, err
+					}
+				case secretsModeCSI:
+					// Mounted as files by the Secrets Store CSI driver, synced to the K8s secret if requested
+					if err := generateSecretProviderObject(ref); err != nil {
+						return appsv1.Deployment{}, err
+					}
+					mountSecretsStore = true
+					if !syncCSISecrets {
+						root.PrintWarning("Secret", envVarName, "of container", *object.Name, "is mounted as", secretsStoreMountPath+"/"+secretProviderAlias(ref), "instead of an environment variable")
+						continue
+					}
+				default:
+					value, err := getSecretValue(ref)
+					if err != nil {
+						return appsv1.Deployment{}, err
+					}
+					generateK8sSecret(ref.secretName, value, namespace)
+				}
 
 				sev := corev1.EnvVar{
 					Name: envVarName,
 					ValueFrom: &corev1.EnvVarSource{
 						SecretKeyRef: &corev1.SecretKeySelector{
 							LocalObjectReference: corev1.LocalObjectReference{
-								Name: secretName,
+								Name: ref.secretName,
 							},
-							Key: secretKey,
+							Key: ref.secretKey,
 						},
 					},
 				}
@@ -194,23 +353,51 @@ func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount in
 			}
 		}
 
+		volumeMounts, err := generateVolumeMounts(object, output.TaskDefinition.ContainerDefinitions)
+		if err != nil {
+			return appsv1.Deployment{}, err
+		}
+
 		c := corev1.Container{
-			Name:    *object.Name,
-			Image:   *object.Image,
-			Ports:   containerPorts,
-			Command: object.Command,
-			Env:     envVars,
+			Name:         *object.Name,
+			Image:        *object.Image,
+			Ports:        containerPorts,
+			Command:      object.Command,
+			Env:          envVars,
+			VolumeMounts: volumeMounts,
+		}
+
+		if mountSecretsStore {
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+				Name:      secretsStoreVolumeName,
+				MountPath: secretsStoreMountPath,
+				ReadOnly:  true,
+			})
 		}
 
+		// ECS health check mapped to probes
+		c.LivenessProbe, c.ReadinessProbe, c.StartupProbe = generateProbes(object.HealthCheck, containerPorts)
+
 		c.Resources = corev1.ResourceRequirements{
 			Limits: corev1.ResourceList{
-				"cpu":    resource.MustParse(fmt.Sprintf("%d%s", object.Cpu, "m")),
-				"memory": resource.MustParse(fmt.Sprintf("%d%s", *object.Memory, "Mi")),
+				"cpu": resource.MustParse(fmt.Sprintf("%d%s", object.Cpu, "m")),
 			},
 		}
+		// Memory is optional at container level when memoryReservation or task memory is set
+		if object.Memory != nil {
+			c.Resources.Limits["memory"] = resource.MustParse(fmt.Sprintf("%d%s", *object.Memory, "Mi"))
+		}
 		kubeContainers = append(kubeContainers, c)
 	}
 
+	// dependsOn conditions become init containers and the start order of the containers
+	initContainers, kubeContainers := arrangeContainers(kubeContainers, output.TaskDefinition.ContainerDefinitions)
+
+	// Secrets Store CSI driver volume for the secrets mounted as files
+	if volume, ok := generateSecretProviderClass(*output.TaskDefinition.Family, namespace); ok {
+		kubeVolumes = append(kubeVolumes, volume)
+	}
+
 	//Create deployment object
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
@@ -221,64 +408,213 @@ func generateDeploymentObject(output ecs.DescribeTaskDefinitionOutput, rCount in
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &rCount,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: kubeLabels,
+				MatchLabels: selectorLabels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: kubeLabels,
 				},
 				Spec: corev1.PodSpec{
-					Containers: kubeContainers,
+					ServiceAccountName: serviceAccountName,
+					InitContainers:     initContainers,
+					Containers:         kubeContainers,
+					Volumes:            kubeVolumes,
 				},
 			},
 		},
 	}
 
+	// Replicas and rolling update strategy from the ECS service
+	if ecsService != nil {
+		applyServiceConfiguration(deployment, *ecsService)
+	}
+
 	if apply {
-		createKubeDeployment(deployment)
+		// Secrets are created once all the keys grouped into them are known
+		for i := range secrets {
+			applyKubeSecret(&secrets[i])
+		}
+		for i := range secretStores {
+			resource := secretStoreResource
+			if secretStores[i].GetKind() == "ClusterSecretStore" {
+				resource = clusterSecretStoreResource
+			}
+			applyKubeObject(&secretStores[i], resource)
+		}
+		for i := range externalSecrets {
+			applyKubeObject(&externalSecrets[i], externalSecretResource)
+		}
+		for i := range secretProviderClasses {
+			applyKubeObject(&secretProviderClasses[i], secretProviderClassResource)
+		}
+		applyKubeDeployment(deployment)
+	}
+
+	// Service and Ingress from the load balancers attached to the ECS service
+	if ecsService != nil && len(ecsService.LoadBalancers) > 0 {
+		attachments, err := getLoadBalancerAttachments(*ecsService)
+		if err != nil {
+			return appsv1.Deployment{}, err
+		}
+		generateLoadBalancerObjects(attachments, *output.TaskDefinition.Family, nameLabels, namespace, apply)
+	}
+	return *deployment, nil
+}
+
+// Clears the objects collected while converting the previous task definition
+func resetGeneratedObjects() {
+	secrets = nil
+	persistentVolumes = nil
+	persistentVolumeClaims = nil
+	kubeServices = nil
+	kubeIngresses = nil
+	secretStores = nil
+	externalSecrets = nil
+	secretProviderClasses = nil
+	secretProviderObjects = nil
+	csiSecretRegion = ""
+	kubeServiceAccounts = nil
+	podIdentityAssociations = nil
+}
+
+// Objects generated along with the deployment of the last converted task definition
+func generatedObjects() []runtime.Object {
+	var objs = []runtime.Object{}
+
+	for i := range kubeServiceAccounts {
+		objs = append(objs, runtime.Object(&kubeServiceAccounts[i]))
 	}
-	return *deployment
+	for i := range podIdentityAssociations {
+		objs = append(objs, runtime.Object(&podIdentityAssociations[i]))
+	}
+	for i := range secrets {
+		objs = append(objs, runtime.Object(&secrets[i]))
+	}
+	for i := range secretStores {
+		objs = append(objs, runtime.Object(&secretStores[i]))
+	}
+	for i := range externalSecrets {
+		objs = append(objs, runtime.Object(&externalSecrets[i]))
+	}
+	for i := range secretProviderClasses {
+		objs = append(objs, runtime.Object(&secretProviderClasses[i]))
+	}
+	for i := range persistentVolumes {
+		objs = append(objs, runtime.Object(&persistentVolumes[i]))
+	}
+	for i := range persistentVolumeClaims {
+		objs = append(objs, runtime.Object(&persistentVolumeClaims[i]))
+	}
+	for i := range kubeServices {
+		objs = append(objs, runtime.Object(&kubeServices[i]))
+	}
+	for i := range kubeIngresses {
+		objs = append(objs, runtime.Object(&kubeIngresses[i]))
+	}
+	return objs
+}
+
+// File name of an object when objects are written one per file, <kind>-<name>.yaml
+func objectFileName(obj runtime.Object) string {
+	accessor, _ := meta.Accessor(obj)
+	return strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind) + "-" + kubeName(accessor.GetName()) + ".yaml"
 }
 
-func generateK8sSpecFile(kubeObjects interface{}, fileName string, yaml bool) {
+// Writes the deployment along with any other generated objects in the spec layout
+func writeK8sSpec(d appsv1.Deployment, fileName string, yaml bool) error {
+	objs := append([]runtime.Object{runtime.Object(&d)}, generatedObjects()...)
+
+	switch specLayout {
+	case specLayoutDocuments:
+		var documents []byte
+		for _, obj := range objs {
+			documents = append(documents, "---\n"...)
+			documents = append(documents, marshalYAML(obj)...)
+		}
+		if fileName != root.StdoutFileName {
+			fileName = fileName + ".yaml"
+		}
+		fmt.Println("Writing K8s multi-document YAML file to : ", fileName)
+		return writeSpecOutput(fileName, documents)
+	case specLayoutFiles:
+		fmt.Println("Writing K8s spec files to : ", fileName)
+		if err := createSpecDir(fileName); err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			objFileName := filepath.Join(fileName, objectFileName(obj))
+			var err error
+			if yaml {
+				err = writeSpecFile(objFileName, marshalYAML(obj))
+			} else {
+				bytes, _ := json.MarshalIndent(obj, "", "  ")
+				err = writeSpecFile(strings.TrimSuffix(objFileName, ".yaml")+".json", bytes)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if len(objs) == 1 {
+			return generateK8sSpecFile(d, fileName, yaml)
+		}
+
+		var list = corev1.List{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "List",
+				APIVersion: "v1",
+			},
+			ListMeta: metav1.ListMeta{},
+		}
+
+		if err := meta.SetList(&list, objs); err != nil {
+			return err
+		}
+		return generateK8sSpecFile(list, fileName, yaml)
+	}
+}
+
+func generateK8sSpecFile(kubeObjects interface{}, fileName string, yaml bool) error {
 	bytes, _ := json.MarshalIndent(kubeObjects, "", "  ")
 	if yaml {
 		y, _ := gyaml.JSONToYAML(bytes)
-		fileName = fileName + ".yaml"
+		if fileName != root.StdoutFileName {
+			fileName = fileName + ".yaml"
+		}
 		fmt.Println("Writing K8s Deployment YAML file to : ", fileName)
-		_ = ioutil.WriteFile(fileName, y, 0644)
-	} else {
+		return writeSpecOutput(fileName, y)
+	}
+	if fileName != root.StdoutFileName {
 		fileName = fileName + ".json"
-		fmt.Println("Writing K8s Deployment JSON file to : ", fileName)
-		_ = ioutil.WriteFile(fileName, bytes, 0644)
 	}
+	fmt.Println("Writing K8s Deployment JSON file to : ", fileName)
+	return writeSpecOutput(fileName, append(bytes, '\n'))
 }
 
-func generateK8sSecret(secretName string, data map[string][]byte, namespace string, apply bool) {
-	// Check if K8s secret exists already and then create
-	var secretExists bool = false
-
+func generateK8sSecret(secretName string, data map[string][]byte, namespace string) {
+	// Check if K8s secret exists already and merge the keys into it
 	for i := range secrets {
 		if secrets[i].ObjectMeta.Name == secretName {
-			secretExists = true
-			break
+			for key, value := range data {
+				secrets[i].Data[key] = value
+			}
+			return
 		}
 	}
 
-	if !secretExists {
-		secret := corev1.Secret{
-			TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretName,
-				Namespace: namespace,
-			},
-			Data: data,
-		}
-		if apply {
-			createKubeSecret(&secret)
-		}
-		secrets = append(secrets, secret)
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Data: make(map[string][]byte),
 	}
+	for key, value := range data {
+		secret.Data[key] = value
+	}
+	secrets = append(secrets, secret)
 }
 
 func getValueFromSecretsManager(secretId string) map[string][]byte {
@@ -299,25 +635,117 @@ func getValueFromSecretsManager(secretId string) map[string][]byte {
 	return transformedMap
 }
 
-func parseSecret(secretArn string) (string, string, map[string][]byte) {
-	var secretName, secretJsonKey string
-	var secretValue map[string][]byte
+// Parameters without a path prefix are grouped into this secret
+const defaultParameterSecretName = "ssm-parameters"
+
+var ssmClient *ssm.Client
+
+func getValueFromParameterStore(parameterName string, region string) ([]byte, error) {
+	if ssmClient == nil {
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		ssmClient = ssm.NewFromConfig(cfg)
+	}
+
+	output, err := ssmClient.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           &parameterName,
+		WithDecryption: true,
+	}, func(o *ssm.Options) {
+		// Parameters referenced by ARN may live in another region
+		if region != "" {
+			o.Region = region
+		}
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get parameter %s: %v", parameterName, err)
+	}
+
+	return []byte(*output.Parameter.Value), nil
+}
+
+// An ECS secret reference to Secrets Manager or Parameter Store
+type secretReference struct {
+	service      string // "secretsmanager" or "ssm"
+	region       string // empty when referenced by name, i.e. the default region
+	secretId     string // Secrets Manager ARN without the JSON key and version, or parameter name
+	jsonKey      string
+	versionStage string
+	versionId    string
+	secretName   string // K8s secret the value is stored in
+	secretKey    string // Key of the value in the K8s secret
+}
+
+// Fetches the values referenced by an ECS secret
+func getSecretValue(ref secretReference) (map[string][]byte, error) {
+	if ref.service == "ssm" {
+		value, err := getValueFromParameterStore(ref.secretId, ref.region)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{ref.secretKey: value}, nil
+	}
+	return getValueFromSecretsManager(ref.secretId), nil
+}
+
+// Parameters are grouped into one K8s secret per path prefix, keyed by the last path segment
+func parseParameter(parameterName string, region string) secretReference {
+	ref := secretReference{
+		service:    "ssm",
+		region:     region,
+		secretId:   parameterName,
+		secretName: defaultParameterSecretName,
+		secretKey:  parameterName,
+	}
+
+	if i := strings.LastIndex(parameterName, "/"); i >= 0 {
+		ref.secretKey = parameterName[i+1:]
+		if prefix := kubeName(parameterName[:i]); prefix != "" {
+			ref.secretName = prefix
+		}
+	}
+
+	return ref
+}
+
+func parseSecret(secretArn string) (secretReference, error) {
+	// Parameters in the same region can be referenced by name instead of ARN
+	if !strings.HasPrefix(secretArn, "arn:") {
+		return parseParameter(secretArn, ""), nil
+	}
+
 	s := strings.Split(secretArn, ":")
 
 	switch secretType := s[2]; secretType {
 	case "secretsmanager":
-		secretName = strings.ToLower(sanitizeValue(s[6], envSpecialChars, "-")) // K8s secret names can be only - lowercase alnum, '-', '.'
-		secretJsonKey = s[7]
-		if secretJsonKey == "" {
-			fmt.Println("Secret JSON key is required in K8s spec")
-			os.Exit(1)
+		// arn:aws:secretsmanager:region:account:secret:name:json-key:version-stage:version-id
+		for len(s) < 10 {
+			s = append(s, "")
+		}
+		ref := secretReference{
+			service:      "secretsmanager",
+			region:       s[3],
+			secretId:     strings.Join(s[:7], ":"),
+			jsonKey:      s[7],
+			versionStage: s[8],
+			versionId:    s[9],
+			secretName:   strings.ToLower(sanitizeValue(s[6], envSpecialChars, "-")), // K8s secret names can be only - lowercase alnum, '-', '.'
+			secretKey:    s[7],
+		}
+		if ref.jsonKey == "" {
+			return ref, errors.New("secret JSON key is required in K8s spec")
+		}
+		return ref, nil
+	case "ssm":
+		// arn:aws:ssm:region:account:parameter/name, names with a path keep their leading slash
+		parameterName := strings.TrimPrefix(strings.Join(s[5:], ":"), "parameter")
+		if !strings.HasPrefix(parameterName, "/") || strings.Count(parameterName, "/") == 1 {
+			parameterName = strings.TrimPrefix(parameterName, "/")
 		}
-		secretValue = getValueFromSecretsManager(strings.Join(s[:7], ":"))
-	case "systemsmanager":
-		fmt.Println("Secrets from Systems Manager not suppported yet.")
-		os.Exit(1)
-		// TODO: Support for secrets from AWS Systems Manager
+		return parseParameter(parameterName, s[3]), nil
 	}
 
-	return secretName, secretJsonKey, secretValue
+	return secretReference{}, fmt.Errorf("unsupported secret reference %s", secretArn)
 }
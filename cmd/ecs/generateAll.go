@@ -0,0 +1,298 @@
+package ecsCmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Flags that select what --all converts, or where, and are not passed on to the conversion of each family
+var generateAllFlags = map[string]bool{
+	"all":             true,
+	"include":         true,
+	"exclude":         true,
+	"tag-filter":      true,
+	"workers":         true,
+	"task-definition": true,
+	"service":         true,
+	"from-file":       true,
+	"file-name":       true,
+}
+
+// Outcome of converting one task family or service with --all
+type conversionResult struct {
+	name     string
+	status   string
+	warnings []string
+	message  string
+}
+
+// Converts every active task family, or every service of the cluster, into its own output directory
+func generateAll(cmd *cobra.Command, cluster string, fileName string) error {
+	include, _ := cmd.Flags().GetString("include")
+	exclude, _ := cmd.Flags().GetString("exclude")
+	tagFilter, _ := cmd.Flags().GetString("tag-filter")
+	workers, _ := cmd.Flags().GetInt32("workers")
+	outputFormat, _ := cmd.Flags().GetString("output-format")
+	layout, _ := cmd.Flags().GetString("layout")
+
+	if fileName == root.StdoutFileName {
+		return errors.New("--all writes a directory per task family and can't be written to stdout")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	tags := parseTagFilter(tagFilter)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return err
+	}
+	client := ecs.NewFromConfig(cfg)
+
+	var names []string
+	if cluster != "" {
+		names, err = listServiceNames(client, cluster)
+	} else {
+		names, err = getTaskDefinitonFamilies("", types.TaskDefinitionFamilyStatusActive)
+	}
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	for _, name := range names {
+		if matchesAny(name, include, true) && !matchesAny(name, exclude, false) {
+			targets = append(targets, name)
+		}
+	}
+	fmt.Println("Converting", len(targets), "of", len(names), "task families with", workers, "workers...")
+
+	// Each family is converted by its own ecs2k8s process, conversion state is global to a process
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("unable to find the ecs2k8s executable: %v", err)
+	}
+	var forwarded []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if !generateAllFlags[f.Name] {
+			forwarded = append(forwarded, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	results := make([]conversionResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := int32(0); w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = convertTarget(client, executable, forwarded, targets[i], cluster, tags, fileName, outputFormat, layout)
+				fmt.Println(results[i].name+":", results[i].status)
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if printConversionSummary(results) {
+		// The failures are listed in the summary
+		return &root.ExitError{Code: 1}
+	}
+	return nil
+}
+
+// Converts one task family or service in a child process, collecting its warnings
+func convertTarget(client *ecs.Client, executable string, forwarded []string, name string, cluster string, tags map[string]string, dir string, outputFormat string, layout string) conversionResult {
+	result := conversionResult{name: name}
+
+	if len(tags) > 0 {
+		resourceTags, err := describeTags(client, name, cluster)
+		if err != nil {
+			result.status, result.message = "failed", err.Error()
+			return result
+		}
+		if !matchesTags(resourceTags, tags) {
+			result.status = "skipped"
+			result.message = "tags do not match"
+			return result
+		}
+	}
+
+	outputDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		result.status, result.message = "failed", err.Error()
+		return result
+	}
+	// Helm, kustomize and the files layout write a directory themselves
+	specFileName := outputDir
+	if outputFormat != outputFormatHelm && outputFormat != outputFormatKustomize && layout != specLayoutFiles {
+		specFileName = filepath.Join(outputDir, name)
+	}
+
+	args := []string{"ecs", "generate-k8s-spec", "--file-name", specFileName}
+	if cluster != "" {
+		args = append(args, "--service", name)
+	} else {
+		args = append(args, "--task-definition", name)
+	}
+	args = append(args, forwarded...)
+
+	output, err := exec.Command(executable, args...).CombinedOutput()
+
+	var lastLine string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lastLine = line
+		if strings.HasPrefix(line, root.WarningPrefix) {
+			result.warnings = append(result.warnings, strings.TrimSpace(strings.TrimPrefix(line, root.WarningPrefix)))
+		}
+	}
+
+	switch {
+	case err != nil:
+		result.status, result.message = "failed", lastLine
+		if lastLine == "" {
+			result.message = err.Error()
+		}
+		// Only removed if nothing was written
+		_ = os.Remove(outputDir)
+	case len(result.warnings) > 0:
+		result.status, result.message = "warnings", result.warnings[0]
+	default:
+		result.status, result.message = "ok", outputDir
+	}
+	return result
+}
+
+// Prints a table of the conversion results, returns true if any conversion failed
+func printConversionSummary(results []conversionResult) bool {
+	counts := make(map[string]int)
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tWARNINGS\tMESSAGE")
+	for _, r := range results {
+		counts[r.status]++
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.name, r.status, len(r.warnings), r.message)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d converted, %d with warnings, %d failed, %d skipped\n", counts["ok"], counts["warnings"], counts["failed"], counts["skipped"])
+	return counts["failed"] > 0
+}
+
+// Lists the names of the services of an ECS cluster
+func listServiceNames(client *ecs.Client, cluster string) ([]string, error) {
+	var services []string
+	paginator := ecs.NewListServicesPaginator(client, &ecs.ListServicesInput{Cluster: &cluster})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list services of cluster %s: %v", cluster, err)
+		}
+		for _, arn := range output.ServiceArns {
+			services = append(services, arn[strings.LastIndex(arn, "/")+1:])
+		}
+	}
+	return services, nil
+}
+
+// Tags of the latest revision of a task family, or of a service when converting a cluster
+func describeTags(client *ecs.Client, name string, cluster string) ([]types.Tag, error) {
+	if cluster == "" {
+		output, err := client.DescribeTaskDefinition(context.TODO(), &ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: &name,
+			Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return output.Tags, nil
+	}
+
+	output, err := client.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{
+		Cluster:  &cluster,
+		Services: []string{name},
+		Include:  []types.ServiceField{types.ServiceFieldTags},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Services) == 0 {
+		return nil, fmt.Errorf("service %s not found", name)
+	}
+	return output.Services[0].Tags, nil
+}
+
+// Parses comma separated key=value tag filters, a key alone matches any value
+func parseTagFilter(filter string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(filter, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 1 {
+			tags[kv[0]] = ""
+		} else {
+			tags[kv[0]] = kv[1]
+		}
+	}
+	return tags
+}
+
+func matchesTags(resourceTags []types.Tag, filter map[string]string) bool {
+	for key, value := range filter {
+		found := false
+		for _, tag := range resourceTags {
+			if tag.Key != nil && *tag.Key == key && (value == "" || (tag.Value != nil && *tag.Value == value)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches a name against comma separated glob patterns, empty patterns match when matchEmpty is set
+func matchesAny(name string, patterns string, matchEmpty bool) bool {
+	if strings.TrimSpace(patterns) == "" {
+		return matchEmpty
+	}
+	for _, pattern := range strings.Split(patterns, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
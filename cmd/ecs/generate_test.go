@@ -0,0 +1,70 @@
+package ecsCmd
+
+import "testing"
+
+func TestParseSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		secretArn string
+		want      secretReference
+	}{
+		{
+			name:      "parameter by name",
+			secretArn: "db-password",
+			want:      secretReference{service: "ssm", secretId: "db-password", secretName: defaultParameterSecretName, secretKey: "db-password"},
+		},
+		{
+			name:      "parameter by name with path",
+			secretArn: "/prod/db/password",
+			want:      secretReference{service: "ssm", secretId: "/prod/db/password", secretName: "prod-db", secretKey: "password"},
+		},
+		{
+			name:      "parameter ARN",
+			secretArn: "arn:aws:ssm:eu-west-1:123456789012:parameter/db-password",
+			want:      secretReference{service: "ssm", region: "eu-west-1", secretId: "db-password", secretName: defaultParameterSecretName, secretKey: "db-password"},
+		},
+		{
+			name:      "parameter ARN with path",
+			secretArn: "arn:aws:ssm:eu-west-1:123456789012:parameter/prod/db/password",
+			want:      secretReference{service: "ssm", region: "eu-west-1", secretId: "/prod/db/password", secretName: "prod-db", secretKey: "password"},
+		},
+		{
+			name:      "secrets manager JSON key",
+			secretArn: "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-creds-AbCdEf:password::",
+			want: secretReference{
+				service:    "secretsmanager",
+				region:     "us-east-1",
+				secretId:   "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-creds-AbCdEf",
+				jsonKey:    "password",
+				secretName: "db-creds-abcdef",
+				secretKey:  "password",
+			},
+		},
+		{
+			name:      "secrets manager version",
+			secretArn: "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-creds-AbCdEf:password:AWSPREVIOUS:v1",
+			want: secretReference{
+				service:      "secretsmanager",
+				region:       "us-east-1",
+				secretId:     "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-creds-AbCdEf",
+				jsonKey:      "password",
+				versionStage: "AWSPREVIOUS",
+				versionId:    "v1",
+				secretName:   "db-creds-abcdef",
+				secretKey:    "password",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSecret(tt.secretArn)
+			if err != nil {
+				t.Fatalf("parseSecret(%q) error = %v", tt.secretArn, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseSecret(%q) = %+v, want %+v", tt.secretArn, got, tt.want)
+			}
+		})
+	}
+}
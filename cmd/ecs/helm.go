@@ -0,0 +1,220 @@
+package ecsCmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	outputFormatJSON = "json"
+	outputFormatYAML = "yaml"
+	outputFormatHelm = "helm"
+)
+
+// Values of the generated chart, overridable per environment
+type helmValues struct {
+	Namespace    string                         `json:"namespace"`
+	ReplicaCount int32                          `json:"replicaCount"`
+	Containers   map[string]helmContainerValues `json:"containers"`
+}
+
+type helmContainerValues struct {
+	Image     helmImage                   `json:"image"`
+	Resources corev1.ResourceRequirements `json:"resources"`
+	Env       []helmEnvVar                `json:"env"`
+}
+
+// Environment variables are a list rather than a map, $(VAR) references only expand the variables defined before them
+type helmEnvVar struct {
+	Name         string         `json:"name"`
+	Value        *string        `json:"value,omitempty"`
+	SecretKeyRef *helmSecretRef `json:"secretKeyRef,omitempty"`
+}
+
+type helmImage struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+type helmSecretRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// A field replaced by a template expression, block expressions render a whole YAML node
+type helmPlaceholder struct {
+	expr  string
+	block bool
+}
+
+// Matches a line holding a placeholder, with the sequence indicator of the first field of a list item
+var helmPlaceholderLine = regexp.MustCompile(`(?m)^( *)(- )?([A-Za-z]+): '?@@([^@]+)@@'?$`)
+
+const helmHelpers = `{{/*
+Image of a container from its repository and tag
+*/}}
+{{- define "CHART.image" -}}
+{{ .repository }}{{ with .tag }}:{{ . }}{{ end }}
+{{- end }}
+
+{{/*
+Environment variables of a container, in the order of the values
+*/}}
+{{- define "CHART.env" -}}
+{{- range .env }}
+- name: {{ .name }}
+{{- with .secretKeyRef }}
+  valueFrom:
+    secretKeyRef:
+      name: {{ .name }}
+      key: {{ .key }}
+{{- else }}
+  value: {{ .value | default "" | quote }}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+// Writes a Helm chart for the task family, with the settings that differ between environments lifted into values
+func writeHelmChart(d appsv1.Deployment, output ecs.DescribeTaskDefinitionOutput, chartDir string) error {
+	td := output.TaskDefinition
+	chartName := kubeName(*td.Family)
+
+	values := helmValues{
+		Namespace:    d.Namespace,
+		ReplicaCount: *d.Spec.Replicas,
+		Containers:   make(map[string]helmContainerValues),
+	}
+
+	deployment, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&d)
+	if err != nil {
+		return fmt.Errorf("unable to generate the Helm chart: %v", err)
+	}
+
+	placeholders := map[string]helmPlaceholder{
+		"replicas": {expr: "{{ .Values.replicaCount }}"},
+	}
+	deployment["spec"].(map[string]interface{})["replicas"] = "@@replicas@@"
+
+	// Init containers and sidecars are in the values along with the containers, container names are unique in a pod
+	podSpec := deployment["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	liftContainers := func(field string, kubeContainers []corev1.Container) {
+		if len(kubeContainers) == 0 {
+			return
+		}
+		containers := podSpec[field].([]interface{})
+		for i, c := range kubeContainers {
+			cv := helmContainerValues{
+				Image:     splitImage(c.Image),
+				Resources: c.Resources,
+				Env:       []helmEnvVar{},
+			}
+			for _, env := range c.Env {
+				ev := helmEnvVar{Name: env.Name}
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					ev.SecretKeyRef = &helmSecretRef{Name: env.ValueFrom.SecretKeyRef.Name, Key: env.ValueFrom.SecretKeyRef.Key}
+				} else {
+					value := env.Value
+					ev.Value = &value
+				}
+				cv.Env = append(cv.Env, ev)
+			}
+			values.Containers[c.Name] = cv
+
+			container := fmt.Sprintf("(index .Values.containers %q)", c.Name)
+			id := field + strconv.Itoa(i)
+			placeholders["image"+id] = helmPlaceholder{expr: fmt.Sprintf(`{{ include "%s.image" %s.image | quote }}`, chartName, container)}
+			placeholders["resources"+id] = helmPlaceholder{expr: fmt.Sprintf("toYaml %s.resources", container), block: true}
+			placeholders["env"+id] = helmPlaceholder{expr: fmt.Sprintf(`include "%s.env" %s | trim`, chartName, container), block: true}
+
+			kc := containers[i].(map[string]interface{})
+			kc["image"] = "@@image" + id + "@@"
+			kc["resources"] = "@@resources" + id + "@@"
+			kc["env"] = "@@env" + id + "@@"
+		}
+	}
+	liftContainers("initContainers", d.Spec.Template.Spec.InitContainers)
+	liftContainers("containers", d.Spec.Template.Spec.Containers)
+
+	templates := map[string][]byte{
+		"deployment.yaml": helmTemplate(deployment, d.Namespace, placeholders),
+	}
+	for _, obj := range generatedObjects() {
+		o, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return fmt.Errorf("unable to generate the Helm chart: %v", err)
+		}
+		templates[objectFileName(obj)] = helmTemplate(o, d.Namespace, nil)
+	}
+
+	appVersion := "latest"
+	if td.Revision > 0 {
+		appVersion = strconv.Itoa(int(td.Revision))
+	}
+	chart := map[string]interface{}{
+		"apiVersion":  "v2",
+		"name":        chartName,
+		"description": "Converted from the ECS task definition " + *td.Family,
+		"type":        "application",
+		"version":     "0.1.0",
+		"appVersion":  appVersion,
+	}
+
+	fmt.Println("Writing Helm chart to : ", chartDir)
+	if err := createSpecDir(filepath.Join(chartDir, "templates")); err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"Chart.yaml":  marshalYAML(chart),
+		"values.yaml": marshalYAML(values),
+		filepath.Join("templates", "_helpers.tpl"): []byte(strings.ReplaceAll(helmHelpers, "CHART", chartName)),
+	}
+	for name, template := range templates {
+		files[filepath.Join("templates", name)] = template
+	}
+	for name, data := range files {
+		if err := writeSpecFile(filepath.Join(chartDir, name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renders an object as a template, replacing its namespace and placeholders with template expressions
+func helmTemplate(obj map[string]interface{}, namespace string, placeholders map[string]helmPlaceholder) []byte {
+	y := string(marshalYAML(obj))
+
+	namespaceLine := regexp.MustCompile(`(?m)^( *)namespace: ` + regexp.QuoteMeta(namespace) + `$`)
+	y = namespaceLine.ReplaceAllString(y, "${1}namespace: {{ .Values.namespace }}")
+
+	y = helmPlaceholderLine.ReplaceAllStringFunc(y, func(line string) string {
+		m := helmPlaceholderLine.FindStringSubmatch(line)
+		indent, item, key, p := m[1], m[2], m[3], placeholders[m[4]]
+		if !p.block {
+			return indent + item + key + ": " + p.expr
+		}
+		nindent := len(indent) + len(item) + 2
+		return fmt.Sprintf("%s%s%s: {{- %s | nindent %d }}", indent, item, key, p.expr, nindent)
+	})
+	return []byte(y)
+}
+
+// Splits an image into repository and tag, images pinned by digest are kept whole
+func splitImage(image string) helmImage {
+	if strings.Contains(image, "@") {
+		return helmImage{Repository: image}
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return helmImage{Repository: image}
+	}
+	return helmImage{Repository: image[:i], Tag: image[i+1:]}
+}
@@ -1,12 +1,20 @@
 package ecsCmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
 	"strings"
 	"time"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	gyaml "github.com/ghodss/yaml"
 )
 
 // Utility function to return a default file name for deployment YAML
@@ -17,19 +25,24 @@ func getDefaultFileName() string {
 }
 
 // Utility function to prompt user to confirm
-func askForConfirmation() bool {
+func askForConfirmation() (bool, error) {
 	var response string
 
 	_, err := fmt.Scanln(&response)
-	if err != nil {
-		log.Fatal(err)
+	if err == io.EOF {
+		fmt.Println()
+		return false, errors.New("no answer on stdin, use --yes to create objects without prompting")
+	}
+	// An empty line is answered with the prompt again
+	if err != nil && err.Error() != "unexpected newline" {
+		return false, err
 	}
 
 	switch strings.ToLower(response) {
 	case "y", "yes":
-		return true
+		return true, nil
 	case "n", "no":
-		return false
+		return false, nil
 	default:
 		fmt.Println("Please type (y)es or (n)o and then press enter:")
 		return askForConfirmation()
@@ -50,6 +63,15 @@ func sanitizeValue(inputString string, specialChars string, replaceChar string)
 	return re.ReplaceAllString(inputString, replaceChar)
 }
 
+// Utility function to convert a string into a valid K8s object name (RFC 1123 label)
+func kubeName(inputString string) string {
+	name := strings.Trim(sanitizeValue(strings.ToLower(inputString), `[^a-z0-9-]+`, "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
 func exists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -60,3 +82,35 @@ func exists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+// Utility function to marshal an object as YAML
+func marshalYAML(obj interface{}) []byte {
+	bytes, _ := json.Marshal(obj)
+	y, _ := gyaml.JSONToYAML(bytes)
+	return y
+}
+
+func createSpecDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create %s: %v", dir, err)
+	}
+	return nil
+}
+
+func writeSpecFile(fileName string, data []byte) error {
+	if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %v", fileName, err)
+	}
+	return nil
+}
+
+// Writes the spec to the file, or to stdout when the file name is -
+func writeSpecOutput(fileName string, data []byte) error {
+	if fileName == root.StdoutFileName {
+		if _, err := specStdout.Write(data); err != nil {
+			return fmt.Errorf("unable to write to stdout: %v", err)
+		}
+		return nil
+	}
+	return writeSpecFile(fileName, data)
+}
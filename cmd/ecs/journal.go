@@ -0,0 +1,225 @@
+package ecsCmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/homedir"
+)
+
+const (
+	journalActionCreated    = "created"
+	journalActionConfigured = "configured"
+)
+
+// An object created or modified by a migration, with its previous version when it already existed
+type journalEntry struct {
+	Action    string                 `json:"action"`
+	Group     string                 `json:"group,omitempty"`
+	Version   string                 `json:"version"`
+	Resource  string                 `json:"resource"`
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	Previous  map[string]interface{} `json:"previous,omitempty"`
+}
+
+// The objects a migration run changed in the K8s cluster, in the order they were applied
+type migrationJournal struct {
+	RunID      string         `json:"runId"`
+	Command    string         `json:"command"`
+	Started    time.Time      `json:"started"`
+	RolledBack bool           `json:"rolledBack,omitempty"`
+	Entries    []journalEntry `json:"entries"`
+}
+
+// Journal of the current migrate-task or migrate-service run, nil when nothing is recorded
+var journal *migrationJournal
+
+// Roll back the objects of the current run when the migration fails
+var rollbackOnFailure bool
+
+// Journals are kept under $HOME/.ecs2k8s/runs, they hold the previous content of updated secrets
+func journalDir() string {
+	return filepath.Join(homedir.HomeDir(), ".ecs2k8s", "runs")
+}
+
+func journalPath(runID string) string {
+	return filepath.Join(journalDir(), runID+".json")
+}
+
+// Starts recording the objects changed by a migration, dry runs change nothing and are not recorded
+func startJournal(command string) {
+	if dryRun != dryRunNone {
+		return
+	}
+	started := time.Now()
+	journal = &migrationJournal{
+		// The process ID keeps runs started in the same second apart
+		RunID:   fmt.Sprintf("%s-%d", started.Format("20060102-150405"), os.Getpid()),
+		Command: command,
+		Started: started,
+	}
+}
+
+// Records an applied object, the journal is saved after every entry so a crash leaves it usable
+func recordApply(resource schema.GroupVersionResource, existing *unstructured.Unstructured, result *unstructured.Unstructured) {
+	if journal == nil {
+		return
+	}
+
+	entry := journalEntry{
+		Action:    journalActionCreated,
+		Group:     resource.Group,
+		Version:   resource.Version,
+		Resource:  resource.Resource,
+		Kind:      result.GetKind(),
+		Namespace: result.GetNamespace(),
+		Name:      result.GetName(),
+	}
+	if existing != nil {
+		entry.Action = journalActionConfigured
+		entry.Previous = existing.Object
+	}
+
+	journal.Entries = append(journal.Entries, entry)
+	if err := saveJournal(journal); err != nil {
+		failMigration(err)
+	}
+}
+
+func saveJournal(j *migrationJournal) error {
+	if err := createSpecDir(journalDir()); err != nil {
+		return err
+	}
+	bytes, _ := json.MarshalIndent(j, "", "  ")
+	if err := ioutil.WriteFile(journalPath(j.RunID), bytes, 0600); err != nil {
+		return fmt.Errorf("unable to write %s: %v", journalPath(j.RunID), err)
+	}
+	return nil
+}
+
+func loadJournal(runID string) (*migrationJournal, error) {
+	bytes, err := ioutil.ReadFile(journalPath(runID))
+	if err != nil {
+		return nil, fmt.Errorf("no migration run %s found in %s", runID, journalDir())
+	}
+	var j migrationJournal
+	if err := json.Unmarshal(bytes, &j); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", journalPath(runID), err)
+	}
+	return &j, nil
+}
+
+// Tells how to roll back the run once the migration is done
+func finishJournal() {
+	if journal == nil || len(journal.Entries) == 0 {
+		return
+	}
+	fmt.Println("Migration run", journal.RunID, "recorded, roll it back with: ecs2k8s ecs rollback --run-id", journal.RunID)
+}
+
+// Prints why the migration failed, rolls back the objects applied so far unless --rollback-on-failure=false and exits
+func failMigration(a ...interface{}) {
+	fmt.Println(a...)
+	if journal != nil && len(journal.Entries) > 0 {
+		if rollbackOnFailure {
+			fmt.Println("Rolling back migration run", journal.RunID)
+			if err := rollbackJournal(journal); err != nil {
+				fmt.Println(err)
+			}
+		} else {
+			fmt.Println("Roll back the objects applied so far with: ecs2k8s ecs rollback --run-id", journal.RunID)
+		}
+	}
+	os.Exit(1)
+}
+
+// Deletes the objects created by the run and restores the previous version of the ones it updated, newest first
+func rollbackJournal(j *migrationJournal) error {
+	config, err := root.KubeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+		if err := rollbackEntry(client, entry); err != nil {
+			fmt.Println("Unable to roll back", entry.Kind, entry.Name+":", err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("migration run %s was partially rolled back, rerun ecs2k8s ecs rollback --run-id %s to retry", j.RunID, j.RunID)
+	}
+
+	j.RolledBack = true
+	if err := saveJournal(j); err != nil {
+		return err
+	}
+	fmt.Println("Migration run", j.RunID, "rolled back")
+	return nil
+}
+
+func rollbackEntry(client dynamic.Interface, entry journalEntry) error {
+	resource := schema.GroupVersionResource{Group: entry.Group, Version: entry.Version, Resource: entry.Resource}
+	var ri dynamic.ResourceInterface = client.Resource(resource)
+	if entry.Namespace != "" {
+		ri = client.Resource(resource).Namespace(entry.Namespace)
+	}
+
+	if entry.Action == journalActionCreated {
+		propagation := metav1.DeletePropagationBackground
+		err := ri.Delete(context.TODO(), entry.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if apierrors.IsNotFound(err) {
+			fmt.Printf("%s %q already deleted.\n", entry.Kind, entry.Name)
+			return nil
+		}
+		if err == nil {
+			fmt.Printf("%s %q deleted.\n", entry.Kind, entry.Name)
+		}
+		return err
+	}
+
+	previous := &unstructured.Unstructured{Object: entry.Previous}
+	current, err := ri.Get(context.TODO(), entry.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		previous.SetResourceVersion("")
+		previous.SetUID("")
+		_, err = ri.Create(context.TODO(), previous, metav1.CreateOptions{})
+		if err == nil {
+			fmt.Printf("%s %q recreated.\n", entry.Kind, entry.Name)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Replacing the whole object also gives the fields taken over by ecs2k8s back to their managers
+	previous.SetResourceVersion(current.GetResourceVersion())
+	unstructured.RemoveNestedField(previous.Object, "status")
+	_, err = ri.Update(context.TODO(), previous, metav1.UpdateOptions{})
+	if err == nil {
+		fmt.Printf("%s %q restored.\n", entry.Kind, entry.Name)
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package ecsCmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+const outputFormatKustomize = "kustomize"
+
+type kustomization struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Resources  []string         `json:"resources,omitempty"`
+	Patches    []kustomizePatch `json:"patches,omitempty"`
+}
+
+type kustomizePatch struct {
+	Path string `json:"path"`
+}
+
+// Objects converted from a task definition, kept until all the task definitions of its family are converted
+type kustomizeVariant struct {
+	output ecs.DescribeTaskDefinitionOutput
+	objs   []runtime.Object
+}
+
+// Writes a base per task family from its first task definition, and an overlay patching it for each of the others
+func writeKustomizations(variants []kustomizeVariant, dir string, overlays string) error {
+	var families []string
+	byFamily := make(map[string][]kustomizeVariant)
+	for _, v := range variants {
+		family := *v.output.TaskDefinition.Family
+		if _, ok := byFamily[family]; !ok {
+			families = append(families, family)
+		}
+		byFamily[family] = append(byFamily[family], v)
+	}
+
+	var overlayNames []string
+	for _, name := range strings.Split(overlays, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			overlayNames = append(overlayNames, name)
+		}
+	}
+
+	for _, family := range families {
+		familyDir := dir
+		if len(families) > 1 {
+			familyDir = dir + "-" + family
+		}
+
+		base := byFamily[family][0]
+		if err := writeKustomizeBase(filepath.Join(familyDir, "base"), base.objs); err != nil {
+			return err
+		}
+
+		for i, v := range byFamily[family][1:] {
+			name := "revision-" + strconv.Itoa(int(v.output.TaskDefinition.Revision))
+			if i < len(overlayNames) {
+				name = overlayNames[i]
+			} else if v.output.TaskDefinition.Revision == 0 {
+				name = "overlay-" + strconv.Itoa(i+1)
+			}
+			if err := writeKustomizeOverlay(filepath.Join(familyDir, "overlays", kubeName(name)), base.objs, v.objs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeKustomizeBase(dir string, objs []runtime.Object) error {
+	fmt.Println("Writing Kustomize base to : ", dir)
+	if err := createSpecDir(dir); err != nil {
+		return err
+	}
+
+	k := newKustomization()
+	for _, obj := range objs {
+		fileName := objectFileName(obj)
+		if err := writeSpecFile(filepath.Join(dir, fileName), marshalYAML(obj)); err != nil {
+			return err
+		}
+		k.Resources = append(k.Resources, fileName)
+	}
+	return writeSpecFile(filepath.Join(dir, "kustomization.yaml"), marshalYAML(k))
+}
+
+// Writes an overlay of the base, objects that differ are patched, new objects are added and missing ones deleted
+func writeKustomizeOverlay(dir string, baseObjs []runtime.Object, objs []runtime.Object) error {
+	fmt.Println("Writing Kustomize overlay to : ", dir)
+	if err := createSpecDir(dir); err != nil {
+		return err
+	}
+
+	k := newKustomization()
+	k.Resources = []string{"../../base"}
+
+	for _, obj := range objs {
+		fileName := objectFileName(obj)
+		baseObj := findObject(baseObjs, obj)
+		if baseObj == nil {
+			if err := writeSpecFile(filepath.Join(dir, fileName), marshalYAML(obj)); err != nil {
+				return err
+			}
+			k.Resources = append(k.Resources, fileName)
+			continue
+		}
+
+		patch, err := createObjectPatch(baseObj, obj)
+		if err != nil {
+			return err
+		}
+		if patch == nil {
+			continue
+		}
+		if err := writeSpecFile(filepath.Join(dir, fileName), marshalYAML(patch)); err != nil {
+			return err
+		}
+		k.Patches = append(k.Patches, kustomizePatch{Path: fileName})
+	}
+
+	for _, baseObj := range baseObjs {
+		if findObject(objs, baseObj) != nil {
+			continue
+		}
+		patch := objectPatchHeader(baseObj)
+		patch["$patch"] = "delete"
+		fileName := objectFileName(baseObj)
+		if err := writeSpecFile(filepath.Join(dir, fileName), marshalYAML(patch)); err != nil {
+			return err
+		}
+		k.Patches = append(k.Patches, kustomizePatch{Path: fileName})
+	}
+
+	return writeSpecFile(filepath.Join(dir, "kustomization.yaml"), marshalYAML(k))
+}
+
+func newKustomization() kustomization {
+	return kustomization{APIVersion: "kustomize.config.k8s.io/v1beta1", Kind: "Kustomization"}
+}
+
+// Finds the object of the same kind, namespace and name
+func findObject(objs []runtime.Object, obj runtime.Object) runtime.Object {
+	a, _ := meta.Accessor(obj)
+	for _, o := range objs {
+		b, _ := meta.Accessor(o)
+		if o.GetObjectKind().GroupVersionKind() == obj.GetObjectKind().GroupVersionKind() && a.GetNamespace() == b.GetNamespace() && a.GetName() == b.GetName() {
+			return o
+		}
+	}
+	return nil
+}
+
+// Patch turning the base object into the overlay object, nil when they are the same
+func createObjectPatch(baseObj runtime.Object, obj runtime.Object) (map[string]interface{}, error) {
+	patch := make(map[string]interface{})
+	if u, ok := baseObj.(*unstructured.Unstructured); ok {
+		// Kustomize has no schema for custom resources, their patches are JSON merge patches
+		patch = jsonMergePatch(u.Object, obj.(*unstructured.Unstructured).Object)
+	} else {
+		original, _ := json.Marshal(baseObj)
+		modified, _ := json.Marshal(obj)
+		p, err := strategicpatch.CreateTwoWayMergePatch(original, modified, obj)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create patch for %s: %v", objectFileName(obj), err)
+		}
+		_ = json.Unmarshal(p, &patch)
+	}
+
+	if len(patch) == 0 {
+		return nil, nil
+	}
+
+	header := objectPatchHeader(obj)
+	if metadata, ok := patch["metadata"].(map[string]interface{}); ok {
+		for k, v := range header["metadata"].(map[string]interface{}) {
+			metadata[k] = v
+		}
+		header["metadata"] = metadata
+	}
+	for k, v := range patch {
+		if _, ok := header[k]; !ok {
+			header[k] = v
+		}
+	}
+	return header, nil
+}
+
+// Fields kustomize finds the patched object by
+func objectPatchHeader(obj runtime.Object) map[string]interface{} {
+	accessor, _ := meta.Accessor(obj)
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	metadata := map[string]interface{}{"name": accessor.GetName()}
+	if accessor.GetNamespace() != "" {
+		metadata["namespace"] = accessor.GetNamespace()
+	}
+	return map[string]interface{}{
+		"apiVersion": gvk.GroupVersion().String(),
+		"kind":       gvk.Kind,
+		"metadata":   metadata,
+	}
+}
+
+// JSON merge patch (RFC 7386) turning original into modified
+func jsonMergePatch(original map[string]interface{}, modified map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for k, v := range modified {
+		o, ok := original[k]
+		if ok && reflect.DeepEqual(o, v) {
+			continue
+		}
+		originalMap, ok1 := o.(map[string]interface{})
+		modifiedMap, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			patch[k] = jsonMergePatch(originalMap, modifiedMap)
+			continue
+		}
+		patch[k] = v
+	}
+	for k := range original {
+		if _, ok := modified[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
@@ -0,0 +1,113 @@
+package ecsCmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/spf13/cobra"
+)
+
+// An ECS cluster as shown by list-clusters
+type ecsClusterSummary struct {
+	Name               string   `json:"name"`
+	Status             string   `json:"status"`
+	ActiveServices     int32    `json:"activeServices"`
+	RunningTasks       int32    `json:"runningTasks"`
+	PendingTasks       int32    `json:"pendingTasks"`
+	ContainerInstances int32    `json:"containerInstances"`
+	CapacityProviders  []string `json:"capacityProviders,omitempty"`
+}
+
+// listClustersCmd represents the listClusters command
+var listClustersCmd = &cobra.Command{
+	Use:   "list-clusters",
+	Short: "Lists the ECS clusters with their services and tasks",
+	Long:  `Lists the ECS clusters with the number of active services, running and pending tasks and container instances`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+
+		if err := validateListOutput(output); err != nil {
+			return err
+		}
+
+		clusters, err := getClusters()
+		if err != nil {
+			return err
+		}
+		printClusters(clusters, output)
+		return nil
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(listClustersCmd)
+	listClustersCmd.Flags().StringP("output", "o", listOutputTable, "Output format, table, json or yaml")
+}
+
+func printClusters(clusters []ecsClusterSummary, output string) {
+	if output != listOutputTable {
+		printListOutput(clusters, output)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tSERVICES\tRUNNING\tPENDING\tINSTANCES")
+	for _, c := range clusters {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", c.Name, c.Status, c.ActiveServices, c.RunningTasks, c.PendingTasks, c.ContainerInstances)
+	}
+	w.Flush()
+}
+
+// Gets the ECS clusters of the account, following all the pages
+func getClusters() ([]ecsClusterSummary, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	client := ecs.NewFromConfig(cfg)
+
+	var arns []string
+	paginator := ecs.NewListClustersPaginator(client, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, output.ClusterArns...)
+	}
+
+	var clusters []ecsClusterSummary
+	// DescribeClusters takes up to 100 clusters
+	for start := 0; start < len(arns); start += 100 {
+		end := start + 100
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		output, err := client.DescribeClusters(context.TODO(), &ecs.DescribeClustersInput{
+			Clusters: arns[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range output.Clusters {
+			clusters = append(clusters, ecsClusterSummary{
+				Name:               *c.ClusterName,
+				Status:             *c.Status,
+				ActiveServices:     c.ActiveServicesCount,
+				RunningTasks:       c.RunningTasksCount,
+				PendingTasks:       c.PendingTasksCount,
+				ContainerInstances: c.RegisteredContainerInstancesCount,
+				CapacityProviders:  c.CapacityProviders,
+			})
+		}
+	}
+
+	return clusters, nil
+}
@@ -0,0 +1,244 @@
+package ecsCmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+)
+
+// An ECS service as shown by list-services, with what keeps it from being migrated as is
+type ecsServiceSummary struct {
+	Name              string   `json:"name"`
+	TaskDefinition    string   `json:"taskDefinition"`
+	Desired           int32    `json:"desired"`
+	Running           int32    `json:"running"`
+	LaunchType        string   `json:"launchType"`
+	LoadBalancers     []string `json:"loadBalancers,omitempty"`
+	ServiceRegistries []string `json:"serviceRegistries,omitempty"`
+	Blockers          []string `json:"blockers,omitempty"`
+}
+
+// listServicesCmd represents the listServices command
+var listServicesCmd = &cobra.Command{
+	Use:   "list-services",
+	Short: "Lists the services of an ECS cluster with their migration readiness",
+	Long:  `Lists the services of an ECS cluster with their task definition, desired and running count, launch type, load balancers, service discovery registrations and the features that are not converted yet`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, _ := cmd.Flags().GetString("cluster")
+		output, _ := cmd.Flags().GetString("output")
+
+		if cluster == "" {
+			return errors.New("cluster required")
+		}
+
+		if err := validateListOutput(output); err != nil {
+			return err
+		}
+
+		services, err := getServiceSummaries(cluster)
+		if err != nil {
+			return err
+		}
+		printServices(services, output)
+		return nil
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(listServicesCmd)
+	listServicesCmd.Flags().StringP("output", "o", listOutputTable, "Output format, table, json or yaml")
+}
+
+func printServices(services []ecsServiceSummary, output string) {
+	if output != listOutputTable {
+		printListOutput(services, output)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTASK DEFINITION\tDESIRED\tRUNNING\tLAUNCH TYPE\tLOAD BALANCERS\tSERVICE DISCOVERY\tREADINESS")
+	for _, s := range services {
+		readiness := "ready"
+		if len(s.Blockers) > 0 {
+			readiness = strings.Join(s.Blockers, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n", s.Name, s.TaskDefinition, s.Desired, s.Running, s.LaunchType,
+			valueOrDash(strings.Join(s.LoadBalancers, ",")), valueOrDash(strings.Join(s.ServiceRegistries, ",")), readiness)
+	}
+	w.Flush()
+}
+
+// Describes the services of a cluster and the task definitions they run
+func getServiceSummaries(cluster string) ([]ecsServiceSummary, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	client := ecs.NewFromConfig(cfg)
+
+	names, err := listServiceNames(client, cluster)
+	if err != nil {
+		return nil, err
+	}
+	taskDefinitions := make(map[string]*types.TaskDefinition)
+
+	var services []ecsServiceSummary
+	// DescribeServices takes up to 10 services
+	for start := 0; start < len(names); start += 10 {
+		end := start + 10
+		if end > len(names) {
+			end = len(names)
+		}
+
+		output, err := client.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{
+			Cluster:  &cluster,
+			Services: names[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, svc := range output.Services {
+			td, ok := taskDefinitions[*svc.TaskDefinition]
+			if !ok {
+				tdOutput, err := client.DescribeTaskDefinition(context.TODO(), &ecs.DescribeTaskDefinitionInput{TaskDefinition: svc.TaskDefinition})
+				if err != nil {
+					return nil, err
+				}
+				td = tdOutput.TaskDefinition
+				taskDefinitions[*svc.TaskDefinition] = td
+			}
+			services = append(services, summarizeService(svc, td))
+		}
+	}
+
+	return services, nil
+}
+
+func summarizeService(svc types.Service, td *types.TaskDefinition) ecsServiceSummary {
+	summary := ecsServiceSummary{
+		Name:           *svc.ServiceName,
+		TaskDefinition: fmt.Sprintf("%s:%d", *td.Family, td.Revision),
+		Desired:        svc.DesiredCount,
+		Running:        svc.RunningCount,
+		LaunchType:     string(svc.LaunchType),
+		Blockers:       migrationBlockers(svc, td),
+	}
+
+	if summary.LaunchType == "" {
+		var providers []string
+		for _, cp := range svc.CapacityProviderStrategy {
+			providers = append(providers, *cp.CapacityProvider)
+		}
+		summary.LaunchType = valueOrDash(strings.Join(providers, ","))
+	}
+
+	for _, lb := range svc.LoadBalancers {
+		if lb.TargetGroupArn != nil {
+			// arn:aws:elasticloadbalancing:region:account:targetgroup/name/id
+			s := strings.Split(*lb.TargetGroupArn, "/")
+			summary.LoadBalancers = append(summary.LoadBalancers, s[len(s)-2])
+		} else if lb.LoadBalancerName != nil {
+			summary.LoadBalancers = append(summary.LoadBalancers, *lb.LoadBalancerName)
+		}
+	}
+
+	for _, sr := range svc.ServiceRegistries {
+		if sr.RegistryArn != nil {
+			// arn:aws:servicediscovery:region:account:service/srv-id
+			summary.ServiceRegistries = append(summary.ServiceRegistries, (*sr.RegistryArn)[strings.LastIndex(*sr.RegistryArn, "/")+1:])
+		}
+	}
+
+	return summary
+}
+
+// Features of the service and its task definition that generateDeploymentObject does not translate
+func migrationBlockers(svc types.Service, td *types.TaskDefinition) []string {
+	var blockers []string
+	add := func(blocker string) {
+		for _, b := range blockers {
+			if b == blocker {
+				return
+			}
+		}
+		blockers = append(blockers, blocker)
+	}
+
+	if svc.SchedulingStrategy == types.SchedulingStrategyDaemon {
+		add("daemon scheduling")
+	}
+	if svc.DeploymentController != nil && svc.DeploymentController.Type != types.DeploymentControllerTypeEcs {
+		add(strings.ToLower(string(svc.DeploymentController.Type)) + " deployments")
+	}
+	if len(svc.ServiceRegistries) > 0 {
+		add("service discovery")
+	}
+	if len(svc.PlacementConstraints) > 0 || len(svc.PlacementStrategy) > 0 || len(td.PlacementConstraints) > 0 {
+		add("placement")
+	}
+	for _, lb := range svc.LoadBalancers {
+		if lb.TargetGroupArn == nil {
+			add("classic load balancer")
+		}
+	}
+
+	for _, v := range td.Volumes {
+		if v.FsxWindowsFileServerVolumeConfiguration != nil {
+			add("fsx volumes")
+		}
+	}
+	if td.ProxyConfiguration != nil {
+		add("app mesh proxy")
+	}
+	if len(td.InferenceAccelerators) > 0 {
+		add("inference accelerators")
+	}
+
+	if len(planContainers(td.ContainerDefinitions).problems) > 0 {
+		add("container dependencies")
+	}
+	for _, c := range td.ContainerDefinitions {
+		if len(c.Links) > 0 {
+			add("links")
+		}
+		if c.FirelensConfiguration != nil {
+			add("firelens")
+		}
+		if c.RepositoryCredentials != nil {
+			add("private registry credentials")
+		}
+		if len(c.Ulimits) > 0 {
+			add("ulimits")
+		}
+		if c.LinuxParameters != nil {
+			add("linux parameters")
+		}
+		if hc := c.HealthCheck; hc != nil && len(hc.Command) > 0 && hc.Command[0] != "CMD" && hc.Command[0] != "CMD-SHELL" && hc.Command[0] != "NONE" {
+			add("health check")
+		}
+		if len(c.EnvironmentFiles) > 0 {
+			add("environment files")
+		}
+		if len(c.EntryPoint) > 0 {
+			add("entry point")
+		}
+		for _, secret := range c.Secrets {
+			// Secrets Manager references need the JSON key of the value
+			if s := strings.Split(*secret.ValueFrom, ":"); len(s) > 2 && s[2] == "secretsmanager" && (len(s) < 8 || s[7] == "") {
+				add("secrets without json key")
+			}
+		}
+	}
+
+	return blockers
+}
@@ -17,54 +17,210 @@ package ecsCmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/spf13/cobra"
 )
 
+const (
+	listOutputTable = "table"
+	listOutputJSON  = "json"
+	listOutputYAML  = "yaml"
+)
+
+// Latest revision of a task definition family, as shown by list-tasks --extended
+type taskFamily struct {
+	Family          string   `json:"family"`
+	Revision        int32    `json:"revision,omitempty"`
+	Compatibilities []string `json:"compatibilities,omitempty"`
+	Cpu             string   `json:"cpu,omitempty"`
+	Memory          string   `json:"memory,omitempty"`
+	Containers      int      `json:"containers,omitempty"`
+}
+
 // listTasksCmd represents the listTasks command
 var listTasksCmd = &cobra.Command{
 	Use:   "list-tasks",
 	Short: "Lists all the ECS tasks that are in active state",
 	Long:  `Lists all the ECS tasks that are in active state`,
-	Run: func(cmd *cobra.Command, args []string) {
-		outFamilies := getTaskDefinitonFamilies()
-		printList(outFamilies)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		familyPrefix, _ := cmd.Flags().GetString("family-prefix")
+		status, _ := cmd.Flags().GetString("status")
+		output, _ := cmd.Flags().GetString("output")
+		extended, _ := cmd.Flags().GetBool("extended")
+
+		if err := validateListOutput(output); err != nil {
+			return err
+		}
+
+		taskStatus := types.TaskDefinitionFamilyStatus(strings.ToUpper(status))
+		switch taskStatus {
+		case types.TaskDefinitionFamilyStatusActive, types.TaskDefinitionFamilyStatusInactive, types.TaskDefinitionFamilyStatusAll:
+		default:
+			return fmt.Errorf("invalid status %s - must be ACTIVE, INACTIVE or ALL", status)
+		}
+
+		families, err := getTaskDefinitonFamilies(familyPrefix, taskStatus)
+		if err != nil {
+			return err
+		}
+		if !extended {
+			printList(families, output)
+			return nil
+		}
+		described, err := describeTaskFamilies(families)
+		if err != nil {
+			return err
+		}
+		printExtendedList(described, output)
+		return nil
 	},
 }
 
 func init() {
 	ecsCmd.AddCommand(listTasksCmd)
+	listTasksCmd.Flags().String("family-prefix", "", "Only list the task definition families starting with this prefix")
+	listTasksCmd.Flags().String("status", string(types.TaskDefinitionFamilyStatusActive), "Status of the task definition families to list, ACTIVE, INACTIVE or ALL")
+	listTasksCmd.Flags().StringP("output", "o", listOutputTable, "Output format, table, json or yaml")
+	listTasksCmd.Flags().Bool("extended", false, "Show the latest revision, launch type compatibility, CPU/memory and number of containers of each family")
+}
 
+func validateListOutput(output string) error {
+	if output != listOutputTable && output != listOutputJSON && output != listOutputYAML {
+		return fmt.Errorf("invalid output %s - must be %s, %s or %s", output, listOutputTable, listOutputJSON, listOutputYAML)
+	}
+	return nil
 }
 
 // Prints list of task definitons
-func printList(output *ecs.ListTaskDefinitionFamiliesOutput) {
-	for _, object := range output.Families {
+func printList(families []string, output string) {
+	if output != listOutputTable {
+		printListOutput(families, output)
+		return
+	}
+	for _, object := range families {
 		fmt.Println(object)
 	}
 }
 
-// Gets active task definiton families from ECS
-func getTaskDefinitonFamilies() (resp *ecs.ListTaskDefinitionFamiliesOutput) {
+// Prints the latest revision of each task definition family
+func printExtendedList(families []taskFamily, output string) {
+	if output != listOutputTable {
+		printListOutput(families, output)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FAMILY\tREVISION\tCOMPATIBILITIES\tCPU\tMEMORY\tCONTAINERS")
+	for _, f := range families {
+		revision := "-"
+		if f.Revision > 0 {
+			revision = fmt.Sprint(f.Revision)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", f.Family, revision, strings.Join(f.Compatibilities, ","), valueOrDash(f.Cpu), valueOrDash(f.Memory), f.Containers)
+	}
+	w.Flush()
+}
+
+// Prints the objects as JSON or YAML
+func printListOutput(objects interface{}, output string) {
+	if output == listOutputYAML {
+		fmt.Print(string(marshalYAML(objects)))
+		return
+	}
+	bytes, _ := json.MarshalIndent(objects, "", "  ")
+	fmt.Println(string(bytes))
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Gets task definiton families from ECS, following all the pages
+func getTaskDefinitonFamilies(familyPrefix string, status types.TaskDefinitionFamilyStatus) ([]string, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	client := ecs.NewFromConfig(cfg)
 
-	// Get active Taskdefinition families
-	output, err := client.ListTaskDefinitionFamilies(context.TODO(), &ecs.ListTaskDefinitionFamiliesInput{
-		Status: "ACTIVE",
-	})
+	input := &ecs.ListTaskDefinitionFamiliesInput{
+		Status: status,
+	}
+	if familyPrefix != "" {
+		input.FamilyPrefix = &familyPrefix
+	}
+
+	var families []string
+	paginator := ecs.NewListTaskDefinitionFamiliesPaginator(client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, output.Families...)
+	}
 
+	return families, nil
+}
+
+// Describes the latest active revision of each family, a few at a time
+func describeTaskFamilies(families []string) ([]taskFamily, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	client := ecs.NewFromConfig(cfg)
+
+	result := make([]taskFamily, len(families))
+	limit := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, family := range families {
+		wg.Add(1)
+		go func(i int, family string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			result[i] = taskFamily{Family: family}
+			output, err := client.DescribeTaskDefinition(context.TODO(), &ecs.DescribeTaskDefinitionInput{TaskDefinition: &family})
+			// Inactive families have no active revision to describe
+			if err != nil {
+				return
+			}
+
+			td := output.TaskDefinition
+			result[i].Revision = td.Revision
+			result[i].Containers = len(td.ContainerDefinitions)
+			compatibilities := td.RequiresCompatibilities
+			if len(compatibilities) == 0 {
+				compatibilities = td.Compatibilities
+			}
+			for _, c := range compatibilities {
+				result[i].Compatibilities = append(result[i].Compatibilities, string(c))
+			}
+			if td.Cpu != nil {
+				result[i].Cpu = *td.Cpu
+			}
+			if td.Memory != nil {
+				result[i].Memory = *td.Memory
+			}
+		}(i, family)
 	}
+	wg.Wait()
 
-	return output
+	return result, nil
 }
@@ -0,0 +1,578 @@
+package ecsCmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	albAnnotationPrefix = "alb.ingress.kubernetes.io/"
+	nlbAnnotationPrefix = "service.beta.kubernetes.io/"
+	albIngressClass     = "alb"
+)
+
+var kubeServices []corev1.Service
+
+var kubeIngresses []networkingv1.Ingress
+
+// A target group of an ECS service together with the load balancer, listeners and rules routing to it
+type loadBalancerAttachment struct {
+	containerName string
+	containerPort int32
+	targetGroup   elbtypes.TargetGroup
+	loadBalancer  elbtypes.LoadBalancer
+	listeners     []elbtypes.Listener
+	certificates  map[string][]string
+	rules         map[string][]elbtypes.Rule
+}
+
+// Looks up the target groups, load balancers, listeners and rules of the ECS service load balancer attachments
+func getLoadBalancerAttachments(svc types.Service) ([]loadBalancerAttachment, error) {
+	var attachments []loadBalancerAttachment
+	var targetGroupArns []string
+
+	for _, lb := range svc.LoadBalancers {
+		if lb.TargetGroupArn == nil {
+			root.PrintWarning("Classic Load Balancer", *lb.LoadBalancerName, "is not supported, skipping")
+			continue
+		}
+		targetGroupArns = append(targetGroupArns, *lb.TargetGroupArn)
+	}
+
+	if len(targetGroupArns) == 0 {
+		return attachments, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	client := elb.NewFromConfig(cfg)
+
+	fmt.Println("Fetching load balancer configuration from ELB...")
+	tgOutput, err := client.DescribeTargetGroups(context.TODO(), &elb.DescribeTargetGroupsInput{
+		TargetGroupArns: targetGroupArns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroups := make(map[string]elbtypes.TargetGroup)
+	var loadBalancerArns []string
+	for _, tg := range tgOutput.TargetGroups {
+		targetGroups[*tg.TargetGroupArn] = tg
+		if len(tg.LoadBalancerArns) > 0 {
+			loadBalancerArns = appendUnique(loadBalancerArns, tg.LoadBalancerArns[0])
+		}
+	}
+
+	// DescribeLoadBalancers without ARNs lists every load balancer in the region
+	if len(loadBalancerArns) == 0 {
+		for _, arn := range targetGroupArns {
+			root.PrintWarning("Target group", arn, "is not attached to a load balancer, skipping")
+		}
+		return attachments, nil
+	}
+
+	lbOutput, err := client.DescribeLoadBalancers(context.TODO(), &elb.DescribeLoadBalancersInput{
+		LoadBalancerArns: loadBalancerArns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancers := make(map[string]elbtypes.LoadBalancer)
+	listeners := make(map[string][]elbtypes.Listener)
+	certificates := make(map[string][]string)
+	rules := make(map[string][]elbtypes.Rule)
+	for _, lb := range lbOutput.LoadBalancers {
+		loadBalancers[*lb.LoadBalancerArn] = lb
+		if listeners[*lb.LoadBalancerArn], err = describeListeners(client, *lb.LoadBalancerArn); err != nil {
+			return nil, err
+		}
+		for _, listener := range listeners[*lb.LoadBalancerArn] {
+			if listener.Protocol == elbtypes.ProtocolEnumHttps || listener.Protocol == elbtypes.ProtocolEnumTls {
+				if certificates[*listener.ListenerArn], err = describeListenerCertificates(client, *listener.ListenerArn); err != nil {
+					return nil, err
+				}
+			}
+			if lb.Type == elbtypes.LoadBalancerTypeEnumApplication {
+				if rules[*listener.ListenerArn], err = describeRules(client, *listener.ListenerArn); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, lb := range svc.LoadBalancers {
+		if lb.TargetGroupArn == nil {
+			continue
+		}
+		tg, found := targetGroups[*lb.TargetGroupArn]
+		if !found || len(tg.LoadBalancerArns) == 0 {
+			root.PrintWarning("Target group", *lb.TargetGroupArn, "is not attached to a load balancer, skipping")
+			continue
+		}
+		loadBalancer := loadBalancers[tg.LoadBalancerArns[0]]
+		attachments = append(attachments, loadBalancerAttachment{
+			containerName: *lb.ContainerName,
+			containerPort: *lb.ContainerPort,
+			targetGroup:   tg,
+			loadBalancer:  loadBalancer,
+			listeners:     listeners[*loadBalancer.LoadBalancerArn],
+			certificates:  certificates,
+			rules:         rules,
+		})
+	}
+
+	return attachments, nil
+}
+
+func describeListeners(client *elb.Client, loadBalancerArn string) ([]elbtypes.Listener, error) {
+	var listeners []elbtypes.Listener
+	input := &elb.DescribeListenersInput{LoadBalancerArn: &loadBalancerArn}
+	for {
+		output, err := client.DescribeListeners(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, output.Listeners...)
+		if output.NextMarker == nil {
+			return listeners, nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
+
+func describeListenerCertificates(client *elb.Client, listenerArn string) ([]string, error) {
+	var certificates []string
+	input := &elb.DescribeListenerCertificatesInput{ListenerArn: &listenerArn}
+	for {
+		output, err := client.DescribeListenerCertificates(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range output.Certificates {
+			// The default certificate goes first, the load balancer controller uses the first one as default
+			if c.IsDefault != nil && *c.IsDefault {
+				certificates = append([]string{*c.CertificateArn}, certificates...)
+			} else {
+				certificates = append(certificates, *c.CertificateArn)
+			}
+		}
+		if output.NextMarker == nil {
+			return certificates, nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
+
+func describeRules(client *elb.Client, listenerArn string) ([]elbtypes.Rule, error) {
+	var rules []elbtypes.Rule
+	input := &elb.DescribeRulesInput{ListenerArn: &listenerArn}
+	for {
+		output, err := client.DescribeRules(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, output.Rules...)
+		if output.NextMarker == nil {
+			return rules, nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
+
+// Generates a Service and Ingress for ALB attachments and a LoadBalancer Service for each NLB
+func generateLoadBalancerObjects(attachments []loadBalancerAttachment, family string, selector map[string]string, namespace string, apply bool) {
+	var albAttachments []loadBalancerAttachment
+
+	for _, a := range attachments {
+		switch a.loadBalancer.Type {
+		case elbtypes.LoadBalancerTypeEnumApplication:
+			albAttachments = append(albAttachments, a)
+		case elbtypes.LoadBalancerTypeEnumNetwork:
+			generateK8sService(generateNLBService(a, family, selector, namespace), apply)
+		default:
+			root.PrintWarning("Load balancer", *a.loadBalancer.LoadBalancerName, "of type", a.loadBalancer.Type, "is not supported, skipping")
+		}
+	}
+
+	if len(albAttachments) == 0 {
+		return
+	}
+
+	// One Service backs the Ingresses of all the ALBs
+	serviceName := kubeName(family)
+	service := corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+		},
+	}
+	for _, a := range albAttachments {
+		service.Spec.Ports = appendServicePort(service.Spec.Ports, a.containerPort, a.containerPort, corev1.ProtocolTCP, a.targetGroup.Protocol)
+		// Instance targets are registered through node ports
+		if a.targetGroup.TargetType == elbtypes.TargetTypeEnumInstance {
+			service.Spec.Type = corev1.ServiceTypeNodePort
+		}
+	}
+	generateK8sService(service, apply)
+
+	loadBalancerNames := make(map[string]bool)
+	for _, a := range albAttachments {
+		if loadBalancerNames[*a.loadBalancer.LoadBalancerName] {
+			continue
+		}
+		loadBalancerNames[*a.loadBalancer.LoadBalancerName] = true
+		generateK8sIngress(generateALBIngress(a.loadBalancer, albAttachments, family, serviceName, namespace), apply)
+	}
+}
+
+// Generates an Ingress for the AWS Load Balancer Controller from the listeners and rules of an ALB
+func generateALBIngress(loadBalancer elbtypes.LoadBalancer, attachments []loadBalancerAttachment, family string, serviceName string, namespace string) networkingv1.Ingress {
+	var listenPorts []map[string]int32
+	var certificates []string
+	var sslPolicy string
+	var healthCheck elbtypes.TargetGroup
+	var targetType elbtypes.TargetTypeEnum
+	var rules []networkingv1.IngressRule
+
+	for _, a := range attachments {
+		if *a.loadBalancer.LoadBalancerArn != *loadBalancer.LoadBalancerArn {
+			continue
+		}
+		healthCheck = a.targetGroup
+		targetType = a.targetGroup.TargetType
+		backend := networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{
+				Name: serviceName,
+				Port: networkingv1.ServiceBackendPort{Number: a.containerPort},
+			},
+		}
+
+		for _, listener := range a.listeners {
+			var listenerRules []elbtypes.Rule
+			for _, rule := range a.rules[*listener.ListenerArn] {
+				if forwardsTo(rule.Actions, *a.targetGroup.TargetGroupArn) {
+					listenerRules = append(listenerRules, rule)
+				}
+			}
+			if len(listenerRules) == 0 && !forwardsTo(listener.DefaultActions, *a.targetGroup.TargetGroupArn) {
+				continue
+			}
+
+			listenPorts = appendListenPort(listenPorts, string(listener.Protocol), *listener.Port)
+			for _, cert := range a.certificates[*listener.ListenerArn] {
+				certificates = appendUnique(certificates, cert)
+			}
+			if listener.SslPolicy != nil {
+				sslPolicy = *listener.SslPolicy
+			}
+
+			sortRulesByPriority(listenerRules)
+			for _, rule := range listenerRules {
+				rules = appendIngressRules(rules, rule, backend)
+			}
+		}
+	}
+
+	annotations := map[string]string{
+		albAnnotationPrefix + "scheme":      string(loadBalancer.Scheme),
+		albAnnotationPrefix + "target-type": string(targetType),
+	}
+	if len(listenPorts) > 0 {
+		ports, _ := json.Marshal(listenPorts)
+		annotations[albAnnotationPrefix+"listen-ports"] = string(ports)
+	}
+	if len(certificates) > 0 {
+		annotations[albAnnotationPrefix+"certificate-arn"] = strings.Join(certificates, ",")
+	}
+	if sslPolicy != "" {
+		annotations[albAnnotationPrefix+"ssl-policy"] = sslPolicy
+	}
+	for key, value := range healthCheckAnnotations(healthCheck) {
+		annotations[albAnnotationPrefix+key] = value
+	}
+
+	ingressClassName := albIngressClass
+	return networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        kubeName(family + "-" + *loadBalancer.LoadBalancerName),
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClassName,
+			Rules:            rules,
+		},
+	}
+}
+
+// Generates a LoadBalancer Service for the AWS Load Balancer Controller from the listeners of an NLB
+func generateNLBService(a loadBalancerAttachment, family string, selector map[string]string, namespace string) corev1.Service {
+	var ports []corev1.ServicePort
+	var tlsPorts []string
+	var certificates []string
+
+	for _, listener := range a.listeners {
+		if !forwardsTo(listener.DefaultActions, *a.targetGroup.TargetGroupArn) {
+			continue
+		}
+		protocol := corev1.ProtocolTCP
+		if listener.Protocol == elbtypes.ProtocolEnumUdp {
+			protocol = corev1.ProtocolUDP
+		}
+		ports = appendServicePort(ports, *listener.Port, a.containerPort, protocol, listener.Protocol)
+		if listener.Protocol == elbtypes.ProtocolEnumTls {
+			tlsPorts = append(tlsPorts, fmt.Sprint(*listener.Port))
+			for _, cert := range a.certificates[*listener.ListenerArn] {
+				certificates = appendUnique(certificates, cert)
+			}
+		}
+	}
+
+	annotations := map[string]string{
+		nlbAnnotationPrefix + "aws-load-balancer-type":            "external",
+		nlbAnnotationPrefix + "aws-load-balancer-nlb-target-type": string(a.targetGroup.TargetType),
+		nlbAnnotationPrefix + "aws-load-balancer-scheme":          string(a.loadBalancer.Scheme),
+	}
+	if len(tlsPorts) > 0 {
+		annotations[nlbAnnotationPrefix+"aws-load-balancer-ssl-ports"] = strings.Join(tlsPorts, ",")
+		annotations[nlbAnnotationPrefix+"aws-load-balancer-ssl-cert"] = strings.Join(certificates, ",")
+	}
+	for key, value := range healthCheckAnnotations(a.targetGroup) {
+		annotations[nlbAnnotationPrefix+nlbHealthCheckAnnotations[key]] = value
+	}
+
+	return corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        kubeName(family + "-" + *a.loadBalancer.LoadBalancerName),
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeLoadBalancer,
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+}
+
+// Service annotations of the health check settings, keyed by the Ingress annotation names the settings are keyed by
+var nlbHealthCheckAnnotations = map[string]string{
+	"healthcheck-protocol":         "aws-load-balancer-healthcheck-protocol",
+	"healthcheck-path":             "aws-load-balancer-healthcheck-path",
+	"healthcheck-port":             "aws-load-balancer-healthcheck-port",
+	"healthcheck-interval-seconds": "aws-load-balancer-healthcheck-interval",
+	"healthcheck-timeout-seconds":  "aws-load-balancer-healthcheck-timeout",
+	"healthy-threshold-count":      "aws-load-balancer-healthcheck-healthy-threshold",
+	"unhealthy-threshold-count":    "aws-load-balancer-healthcheck-unhealthy-threshold",
+	"success-codes":                "aws-load-balancer-healthcheck-success-codes",
+}
+
+// Target group health check settings, keyed by Ingress annotation name without prefix
+func healthCheckAnnotations(tg elbtypes.TargetGroup) map[string]string {
+	annotations := make(map[string]string)
+	if tg.HealthCheckProtocol != "" {
+		annotations["healthcheck-protocol"] = string(tg.HealthCheckProtocol)
+	}
+	if tg.HealthCheckPath != nil {
+		annotations["healthcheck-path"] = *tg.HealthCheckPath
+	}
+	if tg.HealthCheckPort != nil {
+		annotations["healthcheck-port"] = *tg.HealthCheckPort
+	}
+	if tg.HealthCheckIntervalSeconds != nil {
+		annotations["healthcheck-interval-seconds"] = fmt.Sprint(*tg.HealthCheckIntervalSeconds)
+	}
+	if tg.HealthCheckTimeoutSeconds != nil {
+		annotations["healthcheck-timeout-seconds"] = fmt.Sprint(*tg.HealthCheckTimeoutSeconds)
+	}
+	if tg.HealthyThresholdCount != nil {
+		annotations["healthy-threshold-count"] = fmt.Sprint(*tg.HealthyThresholdCount)
+	}
+	if tg.UnhealthyThresholdCount != nil {
+		annotations["unhealthy-threshold-count"] = fmt.Sprint(*tg.UnhealthyThresholdCount)
+	}
+	if tg.Matcher != nil && tg.Matcher.HttpCode != nil {
+		annotations["success-codes"] = *tg.Matcher.HttpCode
+	}
+	return annotations
+}
+
+// Checks if any of the actions forwards traffic to the target group
+func forwardsTo(actions []elbtypes.Action, targetGroupArn string) bool {
+	for _, action := range actions {
+		if action.Type != elbtypes.ActionTypeEnumForward {
+			continue
+		}
+		if action.TargetGroupArn != nil && *action.TargetGroupArn == targetGroupArn {
+			return true
+		}
+		if action.ForwardConfig != nil {
+			for _, tg := range action.ForwardConfig.TargetGroups {
+				if tg.TargetGroupArn != nil && *tg.TargetGroupArn == targetGroupArn {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Translates the host-header and path-pattern conditions of a listener rule into Ingress rules
+func appendIngressRules(rules []networkingv1.IngressRule, rule elbtypes.Rule, backend networkingv1.IngressBackend) []networkingv1.IngressRule {
+	hosts := []string{""}
+	patterns := []string{"/*"}
+
+	for _, condition := range rule.Conditions {
+		if condition.Field == nil {
+			continue
+		}
+		switch *condition.Field {
+		case "host-header":
+			hosts = condition.Values
+			if condition.HostHeaderConfig != nil {
+				hosts = condition.HostHeaderConfig.Values
+			}
+		case "path-pattern":
+			patterns = condition.Values
+			if condition.PathPatternConfig != nil {
+				patterns = condition.PathPatternConfig.Values
+			}
+		default:
+			root.PrintWarning("Listener rule condition", *condition.Field, "cannot be expressed in an Ingress, ignoring")
+		}
+	}
+
+	for _, host := range hosts {
+		var paths []networkingv1.HTTPIngressPath
+		for _, pattern := range patterns {
+			path, pathType := ingressPath(pattern)
+			paths = append(paths, networkingv1.HTTPIngressPath{
+				Path:     path,
+				PathType: &pathType,
+				Backend:  backend,
+			})
+		}
+
+		merged := false
+		for i := range rules {
+			if rules[i].Host == host {
+				rules[i].HTTP.Paths = append(rules[i].HTTP.Paths, paths...)
+				merged = true
+			}
+		}
+		if !merged {
+			rules = append(rules, networkingv1.IngressRule{
+				Host: host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+				},
+			})
+		}
+	}
+
+	return rules
+}
+
+// Converts an ALB path pattern into an Ingress path, wildcards other than a trailing /* are passed through as is
+func ingressPath(pattern string) (string, networkingv1.PathType) {
+	if pattern == "*" || pattern == "/*" {
+		return "/", networkingv1.PathTypePrefix
+	}
+	if strings.HasSuffix(pattern, "/*") && !strings.ContainsAny(strings.TrimSuffix(pattern, "/*"), "*?") {
+		return strings.TrimSuffix(pattern, "/*"), networkingv1.PathTypePrefix
+	}
+	if strings.ContainsAny(pattern, "*?") {
+		return pattern, networkingv1.PathTypeImplementationSpecific
+	}
+	return pattern, networkingv1.PathTypeExact
+}
+
+// Default rules go last, the others in order of priority
+func sortRulesByPriority(rules []elbtypes.Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].IsDefault != rules[j].IsDefault {
+			return !rules[i].IsDefault
+		}
+		var pi, pj int
+		fmt.Sscan(*rules[i].Priority, &pi)
+		fmt.Sscan(*rules[j].Priority, &pj)
+		return pi < pj
+	})
+}
+
+func appendServicePort(ports []corev1.ServicePort, port int32, targetPort int32, protocol corev1.Protocol, elbProtocol elbtypes.ProtocolEnum) []corev1.ServicePort {
+	for _, p := range ports {
+		if p.Port == port && p.Protocol == protocol {
+			return ports
+		}
+	}
+	return append(ports, corev1.ServicePort{
+		Name:       kubeName(fmt.Sprintf("%s-%d", elbProtocol, port)),
+		Port:       port,
+		TargetPort: intstr.FromInt(int(targetPort)),
+		Protocol:   protocol,
+	})
+}
+
+func appendListenPort(listenPorts []map[string]int32, protocol string, port int32) []map[string]int32 {
+	for _, lp := range listenPorts {
+		if lp[protocol] == port {
+			return listenPorts
+		}
+	}
+	return append(listenPorts, map[string]int32{protocol: port})
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func generateK8sService(service corev1.Service, apply bool) {
+	for i := range kubeServices {
+		if kubeServices[i].ObjectMeta.Name == service.ObjectMeta.Name {
+			return
+		}
+	}
+	if apply {
+		applyKubeService(&service)
+	}
+	kubeServices = append(kubeServices, service)
+}
+
+func generateK8sIngress(ingress networkingv1.Ingress, apply bool) {
+	if apply {
+		applyKubeIngress(&ingress)
+	}
+	kubeIngresses = append(kubeIngresses, ingress)
+}
@@ -0,0 +1,32 @@
+package ecsCmd
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestIngressPath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		pathType networkingv1.PathType
+	}{
+		{"*", "/", networkingv1.PathTypePrefix},
+		{"/*", "/", networkingv1.PathTypePrefix},
+		{"/api/*", "/api", networkingv1.PathTypePrefix},
+		{"/api/v1", "/api/v1", networkingv1.PathTypeExact},
+		{"/api/*/users", "/api/*/users", networkingv1.PathTypeImplementationSpecific},
+		{"/img/*.png", "/img/*.png", networkingv1.PathTypeImplementationSpecific},
+		{"/v?/*", "/v?/*", networkingv1.PathTypeImplementationSpecific},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			path, pathType := ingressPath(tt.pattern)
+			if path != tt.path || pathType != tt.pathType {
+				t.Errorf("ingressPath(%q) = %q, %s, want %q, %s", tt.pattern, path, pathType, tt.path, tt.pathType)
+			}
+		})
+	}
+}
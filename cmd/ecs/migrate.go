@@ -17,128 +17,268 @@ package ecsCmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+
+	root "codaglobal/ecs2k8s/cmd/root"
 
 	"github.com/spf13/cobra"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// Field manager of the objects applied by ecs2k8s
+const fieldManager = "ecs2k8s"
+
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
 )
 
 var (
-	taskDefinition     string
-	rCount             int32
-	kubeconfig         string
-	kubeConfigParamter string
-	kConfig            *rest.Config
+	taskDefinition string
+	rCount         int32
 )
 
+// Create objects without prompting for confirmation
+var assumeYes bool
+
+// Take over fields managed by another field manager when applying
+var forceConflicts bool
+
+// "none", "client" to only print the objects, or "server" to have the API server validate them without persisting
+var dryRun string
+
 // migrateCmd represents the migrate command
 var migrateCmd = &cobra.Command{
 	Use:   "migrate-task",
 	Short: "Migrate ECS cluster to the k8s cluster.",
 	Long: `Migrate ECS cluster to the k8s cluster. For example:	`,
-	Run: func(cmd *cobra.Command, args []string) {
+	// --dry-run takes its value after =, a value after a space would be an argument
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		taskDefinition, _ = cmd.Flags().GetString("task-definition")
+		fromFile, _ := cmd.Flags().GetString("from-file")
 		rCount, _ = cmd.Flags().GetInt32("replicas")
 		namespace, _ := cmd.Flags().GetString("namespace")
-		kubeConfigParamter, _ = cmd.Flags().GetString("kubeconfig")
 
-		if taskDefinition == "" {
-			fmt.Println("Task definition required")
-			os.Exit(1)
+		if taskDefinition == "" && fromFile == "" {
+			return errors.New("task definition or --from-file required")
 		}
 
 		if namespace == "" {
-			fmt.Println("Namespace required")
-			os.Exit(1)
+			return errors.New("namespace required")
+		}
+
+		if err := readConversionFlags(cmd); err != nil {
+			return err
+		}
+		if err := readApplyFlags(cmd); err != nil {
+			return err
 		}
 
-		td := getTaskDefiniton(taskDefinition)
-		generateDeploymentObject(td, rCount, namespace, true)
+		tds, err := loadTaskDefinitions(taskDefinition, fromFile)
+		if err != nil {
+			return err
+		}
+		startJournal("migrate-task " + taskDefinition + fromFile)
+		for _, td := range tds {
+			resetGeneratedObjects()
+			d, err := generateDeploymentObject(td, rCount, namespace, true)
+			if err != nil {
+				failMigration(err)
+			}
+			waitForDeployment(d)
+		}
+		finishJournal()
+		return nil
 	},
 }
 
 func init() {
 	ecsCmd.AddCommand(migrateCmd)
-	home := homedir.HomeDir()
-	localKubeconfig := filepath.Join(home, ".kube", "config")
-	kubeConfigenv := os.Getenv("KUBECONFIG")
-
-	// Checks for config file in parameter passed, then in $HOME/.kube directory and then KUBECONFIG environment variable
-	if kubeConfigParamter != "" {
-		if _, err := os.Stat(kubeConfigParamter); err != nil {
-			fmt.Println("No valid kubeconfig found in the specified location,", kubeConfigParamter)
-			os.Exit(1)
-		}
-		kubeconfig = kubeConfigParamter
-	} else if _, err := os.Stat(localKubeconfig); err == nil {
-		kubeconfig = localKubeconfig
-	} else {
-		if _, err := os.Stat(kubeConfigenv); err == nil {
-			kubeconfig = kubeConfigenv
-		} else {
-			fmt.Println("Unable to detect kubeconfig in default location.")
-			os.Exit(1)
-		}
+}
+
+// Reads the flags shared by the commands that create objects in the K8s cluster
+func readApplyFlags(cmd *cobra.Command) error {
+	root.ReadKubeFlags(cmd)
+	assumeYes, _ = cmd.Flags().GetBool("yes")
+	dryRun, _ = cmd.Flags().GetString("dry-run")
+	forceConflicts, _ = cmd.Flags().GetBool("force-conflicts")
+	waitForRollout, _ = cmd.Flags().GetBool("wait")
+	rolloutTimeout, _ = cmd.Flags().GetDuration("timeout")
+	rollbackOnFailure, _ = cmd.Flags().GetBool("rollback-on-failure")
+
+	switch dryRun {
+	case dryRunNone, dryRunClient, dryRunServer:
+		return nil
+	default:
+		return fmt.Errorf("invalid dry run %s - must be %s, %s or %s", dryRun, dryRunNone, dryRunClient, dryRunServer)
+	}
+}
+
+// Asks whether to apply the object, with --dry-run=client the object is printed instead
+func confirmApply(kind string, obj runtime.Object) (bool, error) {
+	accessor, _ := meta.Accessor(obj)
+
+	if dryRun == dryRunClient {
+		fmt.Println("Would apply", kind+":", accessor.GetName(), "(dry run)")
+		fmt.Println("---")
+		fmt.Print(string(marshalYAML(obj)))
+		return false, nil
+	}
+
+	// Nothing is persisted in a server dry run
+	if assumeYes || dryRun == dryRunServer {
+		return true, nil
 	}
 
-	fmt.Println("Using kubeconfig provided in", kubeconfig)
-	kConfig, _ = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	fmt.Print("Proceed with applying ", kind, ": ", accessor.GetName(), " (yes/no): ")
+	return askForConfirmation()
 }
 
-// Creates a K8s deployment in the local K8s cluster
-func createKubeDeployment(deployment *appsv1.Deployment) {
-	clientset, err := kubernetes.NewForConfig(kConfig)
-	if err != nil {
-		panic(err)
+func dryRunSuffix() string {
+	if dryRun == dryRunServer {
+		return " (server dry run)"
 	}
+	return ""
+}
+
+// Applies a K8s deployment to the local K8s cluster
+func applyKubeDeployment(deployment *appsv1.Deployment) {
+	applyKubeObject(deployment, appsv1.SchemeGroupVersion.WithResource("deployments"))
+}
 
-	fmt.Print("Proceed with creating Deployment: ", deployment.ObjectMeta.Name, " (yes/no): ")
+func applyKubeSecret(secret *corev1.Secret) {
+	applyKubeObject(secret, corev1.SchemeGroupVersion.WithResource("secrets"))
+}
 
-	deploy := askForConfirmation()
-	if !deploy {
-		fmt.Println("Operation cancelled by user")
+func applyKubePersistentVolume(pv *corev1.PersistentVolume) {
+	applyKubeObject(pv, corev1.SchemeGroupVersion.WithResource("persistentvolumes"))
+}
+
+func applyKubePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim) {
+	applyKubeObject(pvc, corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"))
+}
+
+func applyKubeService(service *corev1.Service) {
+	applyKubeObject(service, corev1.SchemeGroupVersion.WithResource("services"))
+}
+
+func applyKubeIngress(ingress *networkingv1.Ingress) {
+	applyKubeObject(ingress, networkingv1.SchemeGroupVersion.WithResource("ingresses"))
+}
+
+func applyKubeServiceAccount(sa *corev1.ServiceAccount) {
+	applyKubeObject(sa, corev1.SchemeGroupVersion.WithResource("serviceaccounts"))
+}
+
+// Applies an object with server-side apply, so reruns update the objects created by a previous run in place
+func applyKubeObject(obj runtime.Object, resource schema.GroupVersionResource) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	confirmed, err := confirmApply(kind, obj)
+	if err != nil {
+		failMigration(err)
+	}
+	if !confirmed {
+		if dryRun != dryRunClient {
+			fmt.Println("Operation cancelled by user")
+		}
 		return
 	}
 
-	result, err := clientset.AppsV1().Deployments(deployment.ObjectMeta.Namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	u, err := applyConfiguration(obj)
+	if err != nil {
+		failMigration(err)
+	}
 
+	config, err := root.KubeConfig()
 	if err != nil {
-		log.Println("Deployment failed", err)
-		panic(err)
+		failMigration(err)
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		failMigration(err)
 	}
 
-	fmt.Printf("Created new deployment %q.\n", result.GetObjectMeta().GetName())
-}
+	var ri dynamic.ResourceInterface = client.Resource(resource)
+	if u.GetNamespace() != "" {
+		ri = client.Resource(resource).Namespace(u.GetNamespace())
+	}
 
-func createKubeSecret(secret *corev1.Secret) {
-	fmt.Print("Proceed with creating Secret: ", secret.ObjectMeta.Name, " (yes/no): ")
-	deploy := askForConfirmation()
+	existing, err := ri.Get(context.TODO(), u.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		failMigration("Unable to get", kind, u.GetName()+":", err)
+	}
+	if err != nil {
+		existing = nil
+	}
 
-	if !deploy {
-		return
+	data, err := json.Marshal(u)
+	if err != nil {
+		failMigration("Unable to encode", kind, u.GetName()+":", err)
 	}
 
-	clientset, err := kubernetes.NewForConfig(kConfig)
+	options := metav1.PatchOptions{FieldManager: fieldManager, Force: &forceConflicts}
+	if dryRun == dryRunServer {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
 
+	result, err := ri.Patch(context.TODO(), u.GetName(), types.ApplyPatchType, data, options)
+	if apierrors.IsConflict(err) {
+		fmt.Println("Conflict applying", kind, u.GetName()+":", err)
+		failMigration("The fields are managed by another field manager, use --force-conflicts to take them over")
+	}
 	if err != nil {
-		panic(err)
+		failMigration("Unable to apply", kind, u.GetName()+":", err)
 	}
 
-	secret, err = clientset.CoreV1().Secrets(secret.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	action := "created"
+	if existing != nil {
+		action = "configured"
+		if unchangedObject(existing, result) {
+			action = "unchanged"
+		}
+	}
+	fmt.Printf("%s %q %s%s.\n", kind, result.GetName(), action, dryRunSuffix())
+	if action != "unchanged" {
+		recordApply(resource, existing, result)
+	}
+}
 
+// The object as sent with server-side apply, only the fields set by ecs2k8s are applied and owned
+func applyConfiguration(obj runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 	if err != nil {
-		log.Println("Deployment failed", err)
-		panic(err)
+		return nil, err
 	}
+	u := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u, nil
+}
 
-	fmt.Printf("Created new secret %q.\n", secret.GetObjectMeta().GetName())
+// Whether applying left the object as it was, ignoring the bookkeeping of the apply itself
+func unchangedObject(before *unstructured.Unstructured, after *unstructured.Unstructured) bool {
+	if before.GetResourceVersion() != after.GetResourceVersion() {
+		return false
+	}
+	// Server dry runs don't bump the resource version, compare the content
+	b, a := before.DeepCopy(), after.DeepCopy()
+	for _, u := range []*unstructured.Unstructured{b, a} {
+		unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	}
+	return equality.Semantic.DeepEqual(b.Object, a.Object)
 }
@@ -0,0 +1,85 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package ecsCmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// migrateServiceCmd represents the migrate-service command
+var migrateServiceCmd = &cobra.Command{
+	Use:   "migrate-service",
+	Short: "Migrate an ECS service to the k8s cluster.",
+	Long: `Migrate an ECS service to the k8s cluster. Replicas, rolling update strategy and the task definition revision
+are taken from the service. For example:
+
+	ecs2k8s ecs migrate-service --cluster xxxx --service xxxx --namespace xxxx`,
+	// --dry-run takes its value after =, a value after a space would be an argument
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		service, _ := cmd.Flags().GetString("service")
+		cluster, _ := cmd.Flags().GetString("cluster")
+		rCount, _ = cmd.Flags().GetInt32("replicas")
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		if service == "" || cluster == "" {
+			return errors.New("service and cluster required")
+		}
+
+		if namespace == "" {
+			return errors.New("namespace required")
+		}
+
+		if err := readConversionFlags(cmd); err != nil {
+			return err
+		}
+		if err := readApplyFlags(cmd); err != nil {
+			return err
+		}
+		if err := readCutoverFlags(cmd); err != nil {
+			return err
+		}
+
+		svc, td, err := loadService(cluster, service)
+		if err != nil {
+			return err
+		}
+		// The cutover scales the service down from the count it runs with, not from --replicas
+		ecsDesiredCount := svc.DesiredCount
+		if cmd.Flags().Changed("replicas") {
+			svc.DesiredCount = rCount
+		}
+		ecsService = &svc
+		startJournal("migrate-service " + cluster + "/" + service)
+		d, err := generateDeploymentObject(td, svc.DesiredCount, namespace, true)
+		if err != nil {
+			failMigration(err)
+		}
+		waitForDeployment(d)
+		finishJournal()
+		if cutoverService {
+			svc.DesiredCount = ecsDesiredCount
+			return cutover(svc, namespace, d.Name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(migrateServiceCmd)
+}
@@ -0,0 +1,166 @@
+package ecsCmd
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Defaults applied by ECS when a health check parameter is not set
+const (
+	ecsHealthCheckInterval = 30
+	ecsHealthCheckTimeout  = 5
+	ecsHealthCheckRetries  = 3
+)
+
+var httpProbes bool
+
+var (
+	healthCheckURL   = regexp.MustCompile(`^https?://(localhost|127\.0\.0\.1|0\.0\.0\.0)(:[0-9]+)?(/\S*)?$`)
+	healthCheckHosts = map[string]bool{"localhost": true, "127.0.0.1": true, "0.0.0.0": true}
+	healthCheckExit  = regexp.MustCompile(`\s*\|\|\s*exit\s+1\s*$`)
+)
+
+// Translates an ECS container health check into liveness, readiness and startup probes
+func generateProbes(healthCheck *types.HealthCheck, ports []corev1.ContainerPort) (liveness *corev1.Probe, readiness *corev1.Probe, startup *corev1.Probe) {
+	if healthCheck == nil || len(healthCheck.Command) < 2 {
+		return nil, nil, nil
+	}
+
+	var handler corev1.ProbeHandler
+	switch healthCheck.Command[0] {
+	case "CMD":
+		handler.Exec = &corev1.ExecAction{Command: healthCheck.Command[1:]}
+	case "CMD-SHELL":
+		handler.Exec = &corev1.ExecAction{Command: []string{"/bin/sh", "-c", strings.Join(healthCheck.Command[1:], " ")}}
+	default:
+		root.PrintWarning("Unsupported health check command type", healthCheck.Command[0], "- skipping probes")
+		return nil, nil, nil
+	}
+
+	if httpProbes {
+		if h, ok := networkProbeHandler(healthCheck.Command[1:], ports); ok {
+			handler = h
+		}
+	}
+
+	interval := int32Value(healthCheck.Interval, ecsHealthCheckInterval)
+	timeout := int32Value(healthCheck.Timeout, ecsHealthCheckTimeout)
+	retries := int32Value(healthCheck.Retries, ecsHealthCheckRetries)
+	startPeriod := int32Value(healthCheck.StartPeriod, 0)
+
+	probe := corev1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    interval,
+		TimeoutSeconds:   timeout,
+		FailureThreshold: retries,
+	}
+	liveness = probe.DeepCopy()
+	readiness = probe.DeepCopy()
+
+	// Failures during the ECS start period are not counted, so give the startup probe the same grace
+	if startPeriod > 0 {
+		startup = probe.DeepCopy()
+		startup.FailureThreshold = (startPeriod+interval-1)/interval + retries
+	}
+
+	return liveness, readiness, startup
+}
+
+// Recognises curl/wget/nc calls against a mapped port and returns an HTTP or TCP handler for them
+func networkProbeHandler(command []string, ports []corev1.ContainerPort) (corev1.ProbeHandler, bool) {
+	line := healthCheckExit.ReplaceAllString(strings.Join(command, " "), "")
+	if strings.ContainsAny(line, "|;&><`$") {
+		return corev1.ProbeHandler{}, false
+	}
+
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return corev1.ProbeHandler{}, false
+	}
+
+	switch args[0] {
+	case "curl", "wget":
+		for _, arg := range args[1:] {
+			arg = strings.Trim(arg, `'"`)
+			if !healthCheckURL.MatchString(arg) {
+				continue
+			}
+			u, err := url.Parse(arg)
+			if err != nil {
+				return corev1.ProbeHandler{}, false
+			}
+			port := defaultPort(u.Scheme)
+			if u.Port() != "" {
+				port, _ = strconv.Atoi(u.Port())
+			}
+			if !isMappedPort(int32(port), ports) {
+				return corev1.ProbeHandler{}, false
+			}
+			path := u.EscapedPath()
+			if path == "" {
+				path = "/"
+			}
+			if u.RawQuery != "" {
+				path = path + "?" + u.RawQuery
+			}
+			return corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path:   path,
+					Port:   intstr.FromInt(port),
+					Scheme: corev1.URIScheme(strings.ToUpper(u.Scheme)),
+				},
+			}, true
+		}
+	case "nc":
+		// Expects the form `nc [-z] [-v] <host> <port>`
+		var positional []string
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+		if len(positional) != 2 || !healthCheckHosts[positional[0]] {
+			return corev1.ProbeHandler{}, false
+		}
+		port, err := strconv.Atoi(positional[1])
+		if err != nil || !isMappedPort(int32(port), ports) {
+			return corev1.ProbeHandler{}, false
+		}
+		return corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(port)},
+		}, true
+	}
+
+	return corev1.ProbeHandler{}, false
+}
+
+func isMappedPort(port int32, ports []corev1.ContainerPort) bool {
+	for _, p := range ports {
+		if p.ContainerPort == port {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(scheme string) int {
+	if scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+func int32Value(v *int32, defaultValue int32) int32 {
+	if v == nil || *v == 0 {
+		return defaultValue
+	}
+	return *v
+}
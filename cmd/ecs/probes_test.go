@@ -0,0 +1,68 @@
+package ecsCmd
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNetworkProbeHandler(t *testing.T) {
+	ports := []corev1.ContainerPort{{ContainerPort: 80}, {ContainerPort: 8080}}
+
+	tests := []struct {
+		name    string
+		command []string
+		want    corev1.ProbeHandler
+		ok      bool
+	}{
+		{
+			name:    "curl with path and query",
+			command: []string{"curl -f http://localhost:8080/health?full=1 || exit 1"},
+			want:    corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/health?full=1", Port: intstr.FromInt(8080), Scheme: corev1.URISchemeHTTP}},
+			ok:      true,
+		},
+		{
+			name:    "wget on the default port",
+			command: []string{"wget", "-q", "-O", "-", "'http://127.0.0.1'"},
+			want:    corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80), Scheme: corev1.URISchemeHTTP}},
+			ok:      true,
+		},
+		{
+			name:    "nc",
+			command: []string{"nc", "-z", "localhost", "80"},
+			want:    corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(80)}},
+			ok:      true,
+		},
+		{
+			name:    "port not mapped",
+			command: []string{"curl -f http://localhost:9090/"},
+		},
+		{
+			name:    "remote host",
+			command: []string{"curl -f http://example.com/"},
+		},
+		{
+			name:    "shell pipeline",
+			command: []string{"curl -f http://localhost/ | grep ok"},
+		},
+		{
+			name:    "nc to another host",
+			command: []string{"nc", "-z", "db", "80"},
+		},
+		{
+			name:    "other command",
+			command: []string{"pg_isready"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := networkProbeHandler(tt.command, ports)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("networkProbeHandler(%q) = %+v, %v, want %+v, %v", tt.command, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package ecsCmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Rolls back the K8s objects applied by a migration run",
+	Long: `Rolls back the K8s objects applied by migrate-task or migrate-service. Objects created by the run are deleted and
+objects it updated are restored to their previous version. Run IDs are made of the start time and the process ID, they
+are printed at the end of every migration and each run is recorded in ~/.ecs2k8s/runs. Without --run-id the recorded
+runs are listed. For example:
+
+	ecs2k8s ecs rollback
+	ecs2k8s ecs rollback --run-id 20211201-101500-48213`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runID, _ := cmd.Flags().GetString("run-id")
+		assumeYes, _ = cmd.Flags().GetBool("yes")
+		root.ReadKubeFlags(cmd)
+
+		if runID == "" {
+			return printMigrationRuns()
+		}
+
+		j, err := loadJournal(runID)
+		if err != nil {
+			return err
+		}
+		if j.RolledBack {
+			fmt.Println("Migration run", runID, "was already rolled back")
+			return nil
+		}
+
+		for i := len(j.Entries) - 1; i >= 0; i-- {
+			entry := j.Entries[i]
+			action := "delete"
+			if entry.Action == journalActionConfigured {
+				action = "restore"
+			}
+			fmt.Println("  "+action, entry.Kind, objectKey(entry.Namespace, entry.Name))
+		}
+
+		if !assumeYes {
+			fmt.Print("Proceed with rolling back migration run ", runID, " (yes/no): ")
+			confirmed, err := askForConfirmation()
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Operation cancelled by user")
+				return nil
+			}
+		}
+
+		return rollbackJournal(j)
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().String("run-id", "", "ID of the migration run to roll back, printed at the end of migrate-task and migrate-service")
+}
+
+// Lists the recorded migration runs, newest first
+func printMigrationRuns() error {
+	files, _ := ioutil.ReadDir(journalDir())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tCOMMAND\tOBJECTS\tROLLED BACK")
+	for i := len(files) - 1; i >= 0; i-- {
+		name := files[i].Name()
+		if filepath.Ext(name) != ".json" {
+			continue
+		}
+		j, err := loadJournal(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%t\n", j.RunID, j.Command, len(j.Entries), j.RolledBack)
+	}
+	return w.Flush()
+}
+
+func objectKey(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,216 @@
+package ecsCmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	rolloutPollInterval = 2 * time.Second
+	failureLogLines     = 20
+)
+
+// Wait for the migrated deployments to become available
+var waitForRollout bool
+
+var rolloutTimeout time.Duration
+
+// Pod events and restarts already reported while waiting
+type rolloutWatch struct {
+	events   map[types.UID]bool
+	restarts map[string]int32
+}
+
+// Waits for the deployment with --wait
+func waitForDeployment(d appsv1.Deployment) {
+	if waitForRollout {
+		awaitDeployment(d)
+	}
+}
+
+// Waits until the deployment is available, reporting pod events and restarts, fails the migration if the rollout fails
+func awaitDeployment(d appsv1.Deployment) {
+	if dryRun != dryRunNone {
+		fmt.Println("Not waiting for deployment", d.Name, "in a dry run")
+		return
+	}
+
+	config, err := root.KubeConfig()
+	if err != nil {
+		failMigration(err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		failMigration(err)
+	}
+
+	if _, err := clientset.AppsV1().Deployments(d.Namespace).Get(context.TODO(), d.Name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		fmt.Println("Deployment", d.Name, "was not applied, not waiting for it")
+		return
+	}
+
+	fmt.Println("Waiting up to", rolloutTimeout, "for deployment", d.Name, "to become available...")
+
+	start := metav1.Now()
+	watch := rolloutWatch{events: make(map[types.UID]bool), restarts: make(map[string]int32)}
+	var failure string
+
+	err = wait.PollImmediate(rolloutPollInterval, rolloutTimeout, func() (bool, error) {
+		deployment, err := clientset.AppsV1().Deployments(d.Namespace).Get(context.TODO(), d.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		reportPodActivity(clientset, deployment, start, &watch)
+
+		for _, c := range deployment.Status.Conditions {
+			if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+				failure = c.Message
+				return true, nil
+			}
+		}
+		return deploymentAvailable(deployment), nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		failure = "timed out after " + rolloutTimeout.String()
+	} else if err != nil {
+		failMigration("Unable to get deployment", d.Name+":", err)
+	}
+
+	if failure == "" {
+		fmt.Println("Deployment", d.Name, "is available")
+		return
+	}
+
+	reportFailingContainers(clientset, d)
+	fmt.Println()
+	failMigration("Deployment", d.Name, "did not become available:", failure)
+}
+
+// Whether the latest revision of the deployment is rolled out and all its replicas are available
+func deploymentAvailable(d *appsv1.Deployment) bool {
+	if d.Status.ObservedGeneration < d.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas || d.Status.AvailableReplicas < replicas || d.Status.Replicas > d.Status.UpdatedReplicas {
+		return false
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentAvailable {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Prints the events of the deployment, its replica sets and pods, and container restarts not reported yet
+func reportPodActivity(clientset *kubernetes.Clientset, d *appsv1.Deployment, since metav1.Time, watch *rolloutWatch) {
+	events, err := clientset.CoreV1().Events(d.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err == nil {
+		for _, e := range events.Items {
+			name := e.InvolvedObject.Name
+			// Events recorded through the events.k8s.io API only set the event time
+			timestamp := e.LastTimestamp
+			if timestamp.IsZero() {
+				timestamp = metav1.NewTime(e.EventTime.Time)
+			}
+			if watch.events[e.UID] || timestamp.Before(&since) || (name != d.Name && !strings.HasPrefix(name, d.Name+"-")) {
+				continue
+			}
+			watch.events[e.UID] = true
+			fmt.Printf("  %s %s: %s %s: %s\n", e.InvolvedObject.Kind, name, e.Type, e.Reason, strings.TrimSpace(e.Message))
+		}
+	}
+
+	for _, pod := range listDeploymentPods(clientset, d) {
+		for _, cs := range podContainerStatuses(pod) {
+			key := pod.Name + "/" + cs.Name
+			if cs.RestartCount > watch.restarts[key] {
+				watch.restarts[key] = cs.RestartCount
+				reason := ""
+				if t := cs.LastTerminationState.Terminated; t != nil {
+					reason = fmt.Sprintf(": %s (exit code %d)", t.Reason, t.ExitCode)
+				}
+				fmt.Printf("  Container %s of pod %s restarted %d times%s\n", cs.Name, pod.Name, cs.RestartCount, reason)
+			}
+		}
+	}
+}
+
+// Prints the last termination reason and recent logs of the containers that are not ready
+func reportFailingContainers(clientset *kubernetes.Clientset, d appsv1.Deployment) {
+	deployment, err := clientset.AppsV1().Deployments(d.Namespace).Get(context.TODO(), d.Name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	for _, pod := range listDeploymentPods(clientset, deployment) {
+		for _, cs := range podContainerStatuses(pod) {
+			// Init containers that ran to completion are not ready but did not fail either
+			if cs.Ready || (cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0) {
+				continue
+			}
+
+			fmt.Println()
+			fmt.Println("Container", cs.Name, "of pod", pod.Name+":")
+			if w := cs.State.Waiting; w != nil {
+				fmt.Println("  Waiting:", w.Reason, w.Message)
+			}
+			terminated := cs.State.Terminated
+			if terminated == nil {
+				terminated = cs.LastTerminationState.Terminated
+			}
+			if terminated != nil {
+				fmt.Printf("  Last termination: %s (exit code %d) %s\n", terminated.Reason, terminated.ExitCode, terminated.Message)
+			}
+
+			// Logs of the crashed container when it has restarted
+			tailLines := int64(failureLogLines)
+			logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: cs.Name,
+				TailLines: &tailLines,
+				Previous:  cs.RestartCount > 0,
+			}).DoRaw(context.TODO())
+			if err != nil || len(logs) == 0 {
+				continue
+			}
+			fmt.Println("  Last", failureLogLines, "log lines:")
+			for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+				fmt.Println("    " + line)
+			}
+		}
+	}
+}
+
+func listDeploymentPods(clientset *kubernetes.Clientset, d *appsv1.Deployment) []corev1.Pod {
+	pods, err := clientset.CoreV1().Pods(d.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(d.Spec.Selector.MatchLabels).String(),
+	})
+	if err != nil {
+		return nil
+	}
+	return pods.Items
+}
+
+// Statuses of the init containers, native sidecars included, followed by those of the containers
+func podContainerStatuses(pod corev1.Pod) []corev1.ContainerStatus {
+	return append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+}
@@ -0,0 +1,163 @@
+package ecsCmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	iamModeIRSA        = "irsa"
+	iamModePodIdentity = "pod-identity"
+	iamModeNone        = "none"
+
+	oidcPlaceholder = "<OIDC_PROVIDER>"
+)
+
+var (
+	podIdentityGroupVersion         = schema.GroupVersion{Group: "eks.services.k8s.aws", Version: "v1alpha1"}
+	podIdentityAssociationsResource = podIdentityGroupVersion.WithResource("podidentityassociations")
+)
+
+// How the task IAM role is carried over, "irsa", "pod-identity" or "none"
+var iamMode string
+
+// EKS cluster the pod identity associations are created in
+var eksCluster string
+
+// OIDC provider of the EKS cluster used in the IRSA trust policy report, e.g. oidc.eks.us-east-1.amazonaws.com/id/XXXX
+var oidcProvider string
+
+var trustPolicyReport bool
+
+var kubeServiceAccounts []corev1.ServiceAccount
+
+var podIdentityAssociations []unstructured.Unstructured
+
+func validateIAMMode() error {
+	switch iamMode {
+	case iamModeIRSA, iamModeNone:
+	case iamModePodIdentity:
+		if eksCluster == "" {
+			return errors.New("EKS cluster required for pod identity associations")
+		}
+	default:
+		return fmt.Errorf("invalid IAM mode %s - must be %s, %s or %s", iamMode, iamModeIRSA, iamModePodIdentity, iamModeNone)
+	}
+	return nil
+}
+
+// Generates the ServiceAccount of a task family for its task role, returns its name or empty if there is no task role
+func generateServiceAccount(output ecs.DescribeTaskDefinitionOutput, namespace string, apply bool) string {
+	td := output.TaskDefinition
+
+	if td.ExecutionRoleArn != nil && iamMode != iamModeNone {
+		root.PrintWarning("Execution role", *td.ExecutionRoleArn, "is not carried over, grant its image pull and secret permissions to the node role or the secrets controller")
+	}
+
+	if td.TaskRoleArn == nil || *td.TaskRoleArn == "" || iamMode == iamModeNone {
+		return ""
+	}
+
+	roleArn := *td.TaskRoleArn
+	name := kubeName(*td.Family)
+
+	sa := corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ServiceAccount"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	if iamMode == iamModeIRSA {
+		sa.ObjectMeta.Annotations = map[string]string{root.IRSARoleAnnotation: roleArn}
+	}
+
+	if apply {
+		applyKubeServiceAccount(&sa)
+	}
+	kubeServiceAccounts = append(kubeServiceAccounts, sa)
+
+	if iamMode == iamModePodIdentity {
+		// Managed by the ACK EKS controller, EKS has no native K8s resource for associations
+		association := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"clusterName":    eksCluster,
+					"namespace":      namespace,
+					"serviceAccount": name,
+					"roleARN":        roleArn,
+				},
+			},
+		}
+		association.SetAPIVersion(podIdentityGroupVersion.String())
+		association.SetKind("PodIdentityAssociation")
+		association.SetName(name)
+		association.SetNamespace(namespace)
+
+		if apply {
+			applyKubeObject(&association, podIdentityAssociationsResource)
+		}
+		podIdentityAssociations = append(podIdentityAssociations, association)
+	}
+
+	if trustPolicyReport {
+		printTrustPolicy(roleArn, namespace, name)
+	}
+
+	return name
+}
+
+// Prints the trust policy statement the task role needs to be assumed from the cluster
+func printTrustPolicy(roleArn string, namespace string, serviceAccount string) {
+	var statement map[string]interface{}
+
+	if iamMode == iamModePodIdentity {
+		statement = map[string]interface{}{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "pods.eks.amazonaws.com"},
+			"Action":    []string{"sts:AssumeRole", "sts:TagSession"},
+		}
+	} else {
+		provider := oidcProvider
+		if provider == "" {
+			provider = oidcPlaceholder
+		}
+		provider = strings.TrimPrefix(provider, "https://")
+
+		// arn:partition:iam::account:role/name
+		s := strings.Split(roleArn, ":")
+		partition, account := "aws", ""
+		if len(s) > 4 {
+			partition, account = s[1], s[4]
+		}
+
+		statement = map[string]interface{}{
+			"Effect": "Allow",
+			"Principal": map[string]string{
+				"Federated": "arn:" + partition + ":iam::" + account + ":oidc-provider/" + provider,
+			},
+			"Action": "sts:AssumeRoleWithWebIdentity",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{
+					provider + ":sub": "system:serviceaccount:" + namespace + ":" + serviceAccount,
+					provider + ":aud": "sts.amazonaws.com",
+				},
+			},
+		}
+	}
+
+	bytes, _ := json.MarshalIndent(statement, "", "  ")
+	fmt.Println("Add the following statement to the trust policy of", roleArn+":")
+	fmt.Println(string(bytes))
+}
@@ -0,0 +1,122 @@
+package ecsCmd
+
+import (
+	"context"
+	"fmt"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The ECS service being converted, nil when converting a bare task definition
+var ecsService *types.Service
+
+// Progress deadline used when the ECS deployment circuit breaker is enabled
+var progressDeadline int32
+
+// Fetch service from an ECS cluster
+func getService(cluster string, service string) (types.Service, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	fmt.Println("Fetching service", service, "from ECS cluster", cluster+"...")
+	if err != nil {
+		return types.Service{}, err
+	}
+
+	client := ecs.NewFromConfig(cfg)
+
+	output, err := client.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{
+		Cluster:  &cluster,
+		Services: []string{service},
+		Include:  []types.ServiceField{"TAGS"},
+	})
+
+	if err != nil {
+		return types.Service{}, err
+	}
+
+	if len(output.Services) == 0 {
+		reason := "not found"
+		if len(output.Failures) > 0 && output.Failures[0].Reason != nil {
+			reason = *output.Failures[0].Reason
+		}
+		return types.Service{}, fmt.Errorf("unable to describe service %s: %s", service, reason)
+	}
+
+	return output.Services[0], nil
+}
+
+// Loads the service and the task definition revision it currently runs
+func loadService(cluster string, service string) (types.Service, ecs.DescribeTaskDefinitionOutput, error) {
+	svc, err := getService(cluster, service)
+	if err != nil {
+		return svc, ecs.DescribeTaskDefinitionOutput{}, err
+	}
+
+	if svc.SchedulingStrategy == types.SchedulingStrategyDaemon {
+		root.PrintWarning("Service", service, "uses the DAEMON scheduling strategy, a DaemonSet may be a closer match than the generated Deployment")
+	}
+	if svc.DeploymentController != nil && svc.DeploymentController.Type != types.DeploymentControllerTypeEcs {
+		root.PrintWarning("Service", service, "uses the", svc.DeploymentController.Type, "deployment controller, converting to a rolling update")
+	}
+
+	td, err := getTaskDefiniton(*svc.TaskDefinition)
+	return svc, td, err
+}
+
+// Applies the desired count and deployment configuration of an ECS service to a deployment
+func applyServiceConfiguration(deployment *appsv1.Deployment, svc types.Service) {
+	replicas := svc.DesiredCount
+	deployment.Spec.Replicas = &replicas
+
+	dc := svc.DeploymentConfiguration
+	if dc == nil {
+		return
+	}
+
+	// ECS defaults are 200% maximum and 100% minimum healthy
+	maxPercent, minHealthyPercent := int32(200), int32(100)
+	if dc.MaximumPercent != nil {
+		maxPercent = *dc.MaximumPercent
+	}
+	if dc.MinimumHealthyPercent != nil {
+		minHealthyPercent = *dc.MinimumHealthyPercent
+	}
+
+	maxSurge := intstr.FromString(fmt.Sprintf("%d%%", clampPercent(maxPercent-100)))
+	maxUnavailable := intstr.FromString(fmt.Sprintf("%d%%", clampPercent(100-minHealthyPercent)))
+	// K8s rejects a rolling update that can neither add nor remove pods
+	if maxSurge.String() == "0%" && maxUnavailable.String() == "0%" {
+		maxSurge = intstr.FromInt(1)
+	}
+
+	deployment.Spec.Strategy = appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+
+	if cb := dc.DeploymentCircuitBreaker; cb != nil && cb.Enable {
+		deadline := progressDeadline
+		deployment.Spec.ProgressDeadlineSeconds = &deadline
+		if cb.Rollback {
+			root.PrintWarning("ECS rolls back failed deployments automatically, K8s only reports them. Use `kubectl rollout undo` once the progress deadline is exceeded")
+		}
+	}
+}
+
+func clampPercent(percent int32) int32 {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
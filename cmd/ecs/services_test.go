@@ -0,0 +1,86 @@
+package ecsCmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestApplyServiceConfiguration(t *testing.T) {
+	rollingUpdate := func(maxSurge intstr.IntOrString, maxUnavailable intstr.IntOrString) appsv1.DeploymentStrategy {
+		return appsv1.DeploymentStrategy{
+			Type:          appsv1.RollingUpdateDeploymentStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		svc              types.Service
+		wantStrategy     appsv1.DeploymentStrategy
+		wantDeadline     *int32
+		wantReplicaCount int32
+	}{
+		{
+			name:             "no deployment configuration",
+			svc:              types.Service{DesiredCount: 3},
+			wantReplicaCount: 3,
+		},
+		{
+			name:             "ECS defaults",
+			svc:              types.Service{DesiredCount: 2, DeploymentConfiguration: &types.DeploymentConfiguration{}},
+			wantStrategy:     rollingUpdate(intstr.FromString("100%"), intstr.FromString("0%")),
+			wantReplicaCount: 2,
+		},
+		{
+			name: "surge and unavailable",
+			svc: types.Service{DesiredCount: 4, DeploymentConfiguration: &types.DeploymentConfiguration{
+				MaximumPercent:        aws.Int32(150),
+				MinimumHealthyPercent: aws.Int32(50),
+			}},
+			wantStrategy:     rollingUpdate(intstr.FromString("50%"), intstr.FromString("50%")),
+			wantReplicaCount: 4,
+		},
+		{
+			name: "neither surge nor unavailable",
+			svc: types.Service{DesiredCount: 1, DeploymentConfiguration: &types.DeploymentConfiguration{
+				MaximumPercent:        aws.Int32(100),
+				MinimumHealthyPercent: aws.Int32(100),
+			}},
+			wantStrategy:     rollingUpdate(intstr.FromInt(1), intstr.FromString("0%")),
+			wantReplicaCount: 1,
+		},
+		{
+			name: "circuit breaker",
+			svc: types.Service{DesiredCount: 1, DeploymentConfiguration: &types.DeploymentConfiguration{
+				MaximumPercent:           aws.Int32(300),
+				MinimumHealthyPercent:    aws.Int32(0),
+				DeploymentCircuitBreaker: &types.DeploymentCircuitBreaker{Enable: true},
+			}},
+			wantStrategy:     rollingUpdate(intstr.FromString("100%"), intstr.FromString("100%")),
+			wantDeadline:     aws.Int32(600),
+			wantReplicaCount: 1,
+		},
+	}
+
+	progressDeadline = 600
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d appsv1.Deployment
+			applyServiceConfiguration(&d, tt.svc)
+			if *d.Spec.Replicas != tt.wantReplicaCount {
+				t.Errorf("replicas = %d, want %d", *d.Spec.Replicas, tt.wantReplicaCount)
+			}
+			if !reflect.DeepEqual(d.Spec.Strategy, tt.wantStrategy) {
+				t.Errorf("strategy = %+v, want %+v", d.Spec.Strategy, tt.wantStrategy)
+			}
+			if !reflect.DeepEqual(d.Spec.ProgressDeadlineSeconds, tt.wantDeadline) {
+				t.Errorf("progress deadline = %v, want %v", d.Spec.ProgressDeadlineSeconds, tt.wantDeadline)
+			}
+		})
+	}
+}
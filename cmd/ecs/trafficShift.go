@@ -0,0 +1,501 @@
+package ecsCmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	elb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/homedir"
+)
+
+var (
+	targetGroupBindingGroupVersion = schema.GroupVersion{Group: "elbv2.k8s.aws", Version: "v1beta1"}
+	targetGroupBindingResource     = targetGroupBindingGroupVersion.WithResource("targetgroupbindings")
+)
+
+// A listener rule, or the default actions of a listener when RuleArn is empty, forwarding to the ECS target group
+type trafficRoute struct {
+	ListenerArn       string            `json:"listenerArn"`
+	RuleArn           string            `json:"ruleArn,omitempty"`
+	ECSTargetGroupArn string            `json:"ecsTargetGroupArn"`
+	K8sTargetGroupArn string            `json:"k8sTargetGroupArn"`
+	Actions           []elbtypes.Action `json:"actions"`
+}
+
+// Original actions of the routes of an ECS service whose traffic is being shifted, kept until they are restored
+type trafficRecord struct {
+	Cluster string         `json:"cluster"`
+	Service string         `json:"service"`
+	Started time.Time      `json:"started"`
+	Routes  []trafficRoute `json:"routes"`
+}
+
+// shiftTrafficCmd represents the shift-traffic command
+var shiftTrafficCmd = &cobra.Command{
+	Use:   "shift-traffic",
+	Short: "Shifts ALB traffic from an ECS service to its K8s deployment",
+	Long: `Shifts ALB traffic from an ECS service to its K8s deployment. The pods are registered in a target group through a
+TargetGroupBinding of the AWS Load Balancer Controller, and the listener rules forwarding to the ECS target group are
+changed to weighted forwards between both target groups. The K8s weight is raised through --traffic-steps with
+--traffic-pause between them, the K8s targets must stay healthy and the deployment available or the original rules are
+restored. The original rules are recorded so the shift can be reverted with --restore. For example:
+
+	ecs2k8s ecs shift-traffic --cluster xxxx --service xxxx --namespace xxxx --traffic-steps 10,50,100
+	ecs2k8s ecs shift-traffic --cluster xxxx --service xxxx --restore`,
+	// --dry-run takes its value after =, a value after a space would be an argument
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		service, _ := cmd.Flags().GetString("service")
+		cluster, _ := cmd.Flags().GetString("cluster")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		restore, _ := cmd.Flags().GetBool("restore")
+		k8sTargetGroup, _ := cmd.Flags().GetString("k8s-target-group")
+		steps, _ := cmd.Flags().GetString("traffic-steps")
+		pause, _ := cmd.Flags().GetDuration("traffic-pause")
+
+		if service == "" || cluster == "" {
+			return errors.New("service and cluster required")
+		}
+
+		if err := readApplyFlags(cmd); err != nil {
+			return err
+		}
+
+		if restore {
+			return restoreTraffic(cluster, service)
+		}
+
+		if namespace == "" {
+			return errors.New("namespace required")
+		}
+
+		weights, err := parseTrafficSteps(steps)
+		if err != nil {
+			return err
+		}
+
+		svc, err := getService(cluster, service)
+		if err != nil {
+			return err
+		}
+		family := taskDefinitionFamily(*svc.TaskDefinition)
+
+		attachments, err := getLoadBalancerAttachments(svc)
+		if err != nil {
+			return err
+		}
+
+		startJournal("shift-traffic " + cluster + "/" + service)
+		routes, err := prepareTrafficRoutes(attachments, k8sTargetGroup, family, namespace)
+		if err != nil {
+			failMigration(err)
+		}
+		finishJournal()
+
+		return shiftTraffic(cluster, service, routes, weights, pause, appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: family, Namespace: namespace}})
+	},
+}
+
+func init() {
+	ecsCmd.AddCommand(shiftTrafficCmd)
+	shiftTrafficCmd.Flags().String("k8s-target-group", "", "ARN of the target group the K8s pods are registered in, defaults to a copy of the ECS target group named k8s-<name>")
+	shiftTrafficCmd.Flags().String("traffic-steps", "10,25,50,100", "Comma separated percentages of the traffic sent to K8s at each step")
+	shiftTrafficCmd.Flags().Duration("traffic-pause", time.Minute, "Pause after each step before checking that the K8s targets are still healthy")
+	shiftTrafficCmd.Flags().Bool("restore", false, "Restore the listener rules the ECS service had before traffic was shifted")
+}
+
+func parseTrafficSteps(steps string) ([]int32, error) {
+	var weights []int32
+	for _, step := range strings.Split(steps, ",") {
+		weight, err := strconv.Atoi(strings.TrimSpace(step))
+		if err != nil || weight < 0 || weight > 100 || (len(weights) > 0 && int32(weight) <= weights[len(weights)-1]) {
+			return nil, fmt.Errorf("invalid traffic steps %s - must be increasing percentages between 0 and 100", steps)
+		}
+		weights = append(weights, int32(weight))
+	}
+	return weights, nil
+}
+
+func trafficPath(cluster string, service string) string {
+	return filepath.Join(homedir.HomeDir(), ".ecs2k8s", "traffic", kubeName(cluster)+"-"+kubeName(service)+".json")
+}
+
+func newELBClient() (*elb.Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return elb.NewFromConfig(cfg), nil
+}
+
+// Binds the pods to a K8s target group for each ALB target group of the service and finds the rules forwarding to it
+func prepareTrafficRoutes(attachments []loadBalancerAttachment, k8sTargetGroupArn string, family string, namespace string) ([]trafficRoute, error) {
+	client, err := newELBClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []trafficRoute
+	for _, a := range attachments {
+		if a.loadBalancer.Type != elbtypes.LoadBalancerTypeEnumApplication {
+			root.PrintWarning("Load balancer", *a.loadBalancer.LoadBalancerName, "is not an ALB, weighted forwarding is not supported, skipping")
+			continue
+		}
+
+		k8sTargetGroup := k8sTargetGroupArn
+		if k8sTargetGroup == "" {
+			if k8sTargetGroup, err = createK8sTargetGroup(client, a.targetGroup); err != nil {
+				return nil, err
+			}
+		}
+		applyTargetGroupBinding(k8sTargetGroup, a, family, namespace)
+
+		for _, listener := range a.listeners {
+			for _, rule := range a.rules[*listener.ListenerArn] {
+				if rule.IsDefault || !forwardsTo(rule.Actions, *a.targetGroup.TargetGroupArn) {
+					continue
+				}
+				routes = append(routes, trafficRoute{
+					ListenerArn:       *listener.ListenerArn,
+					RuleArn:           *rule.RuleArn,
+					ECSTargetGroupArn: *a.targetGroup.TargetGroupArn,
+					K8sTargetGroupArn: k8sTargetGroup,
+					Actions:           rule.Actions,
+				})
+			}
+			if forwardsTo(listener.DefaultActions, *a.targetGroup.TargetGroupArn) {
+				routes = append(routes, trafficRoute{
+					ListenerArn:       *listener.ListenerArn,
+					ECSTargetGroupArn: *a.targetGroup.TargetGroupArn,
+					K8sTargetGroupArn: k8sTargetGroup,
+					Actions:           listener.DefaultActions,
+				})
+			}
+		}
+	}
+
+	if len(routes) == 0 {
+		return nil, errors.New("no ALB listener rules forward to the target groups of the ECS service")
+	}
+	return routes, nil
+}
+
+// Creates a target group for the pods with the settings of the ECS one, ELB returns the existing one on reruns
+func createK8sTargetGroup(client *elb.Client, ecsTargetGroup elbtypes.TargetGroup) (string, error) {
+	name := "k8s-" + *ecsTargetGroup.TargetGroupName
+	// Target group names are limited to 32 characters
+	if len(name) > 32 {
+		name = strings.TrimRight(name[:32], "-")
+	}
+
+	if dryRun != dryRunNone {
+		fmt.Println("Would create target group", name, "(dry run)")
+		return "<" + name + ">", nil
+	}
+
+	output, err := client.CreateTargetGroup(context.TODO(), &elb.CreateTargetGroupInput{
+		Name:                       &name,
+		TargetType:                 elbtypes.TargetTypeEnumIp,
+		Protocol:                   ecsTargetGroup.Protocol,
+		ProtocolVersion:            ecsTargetGroup.ProtocolVersion,
+		Port:                       ecsTargetGroup.Port,
+		VpcId:                      ecsTargetGroup.VpcId,
+		HealthCheckEnabled:         ecsTargetGroup.HealthCheckEnabled,
+		HealthCheckProtocol:        ecsTargetGroup.HealthCheckProtocol,
+		HealthCheckPath:            ecsTargetGroup.HealthCheckPath,
+		HealthCheckPort:            ecsTargetGroup.HealthCheckPort,
+		HealthCheckIntervalSeconds: ecsTargetGroup.HealthCheckIntervalSeconds,
+		HealthCheckTimeoutSeconds:  ecsTargetGroup.HealthCheckTimeoutSeconds,
+		HealthyThresholdCount:      ecsTargetGroup.HealthyThresholdCount,
+		UnhealthyThresholdCount:    ecsTargetGroup.UnhealthyThresholdCount,
+		Matcher:                    ecsTargetGroup.Matcher,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create target group %s: %v", name, err)
+	}
+	fmt.Println("Target group", name, "created")
+	return *output.TargetGroups[0].TargetGroupArn, nil
+}
+
+// Registers the pods behind the generated Service in the target group with the AWS Load Balancer Controller
+func applyTargetGroupBinding(targetGroupArn string, a loadBalancerAttachment, family string, namespace string) {
+	binding := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"targetGroupARN": targetGroupArn,
+				"targetType":     string(elbtypes.TargetTypeEnumIp),
+				"serviceRef": map[string]interface{}{
+					"name": kubeName(family),
+					"port": int64(a.containerPort),
+				},
+			},
+		},
+	}
+	binding.SetAPIVersion(targetGroupBindingGroupVersion.String())
+	binding.SetKind("TargetGroupBinding")
+	binding.SetName(kubeName(family + "-" + *a.targetGroup.TargetGroupName))
+	binding.SetNamespace(namespace)
+
+	applyKubeObject(&binding, targetGroupBindingResource)
+}
+
+// Raises the K8s weight of the routes step by step while the K8s targets stay healthy
+func shiftTraffic(cluster string, service string, routes []trafficRoute, weights []int32, pause time.Duration, d appsv1.Deployment) error {
+	fmt.Println("Shifting traffic of ECS service", service, "to deployment", d.Name+",", strings.Trim(fmt.Sprint(weights), "[]"), "percent")
+	if dryRun != dryRunNone {
+		for _, route := range routes {
+			fmt.Println("Would weight", routeName(route), "between", route.ECSTargetGroupArn, "and", route.K8sTargetGroupArn, "(dry run)")
+		}
+		return nil
+	}
+
+	awaitDeployment(d)
+
+	if !assumeYes {
+		fmt.Print("Proceed with shifting traffic of ECS service ", service, " (yes/no): ")
+		confirmed, err := askForConfirmation()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Operation cancelled by user")
+			return nil
+		}
+	}
+
+	// A shift that was interrupted already changed the rules, keep the rules it started from
+	record, err := loadTrafficRecord(cluster, service)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		if err := saveTrafficRecord(&trafficRecord{Cluster: cluster, Service: service, Started: time.Now(), Routes: routes}); err != nil {
+			return err
+		}
+	}
+
+	client, err := newELBClient()
+	if err != nil {
+		return err
+	}
+
+	// ALB only health checks the targets of a target group a listener forwards to, they are "unused" before that
+	for _, route := range routes {
+		if err := setRouteActions(client, route, weightedActions(route, 0)); err != nil {
+			return err
+		}
+	}
+	for _, route := range routes {
+		if !awaitHealthyTargets(client, route.K8sTargetGroupArn) {
+			fmt.Println("K8s targets of ECS service", service, "did not become healthy, restoring its listener rules")
+			if err := restoreTraffic(cluster, service); err != nil {
+				return err
+			}
+			return fmt.Errorf("traffic shift of ECS service %s reverted", service)
+		}
+	}
+
+	kubeConfig, err := root.KubeConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, weight := range weights {
+		for _, route := range routes {
+			if err := setRouteActions(client, route, weightedActions(route, weight)); err != nil {
+				return err
+			}
+		}
+		fmt.Println("Sending", weight, "percent of the traffic of ECS service", service, "to K8s")
+
+		time.Sleep(pause)
+
+		// Health gate, every K8s target has to stay healthy under its share of the traffic
+		deployment, err := clientset.AppsV1().Deployments(d.Namespace).Get(context.TODO(), d.Name, metav1.GetOptions{})
+		healthy := err == nil && deploymentAvailable(deployment)
+		for _, route := range routes {
+			if unhealthy := unhealthyTargets(client, route.K8sTargetGroupArn); unhealthy != "" {
+				fmt.Println("Target group", route.K8sTargetGroupArn, "has unhealthy targets:", unhealthy)
+				healthy = false
+			}
+		}
+		if !healthy {
+			fmt.Println("K8s backend of ECS service", service, "is not healthy, restoring its listener rules")
+			if err := restoreTraffic(cluster, service); err != nil {
+				return err
+			}
+			return fmt.Errorf("traffic shift of ECS service %s reverted", service)
+		}
+	}
+
+	fmt.Println("Traffic of ECS service", service, "shifted, restore it with: ecs2k8s ecs shift-traffic --cluster", cluster, "--service", service, "--restore")
+	return nil
+}
+
+// The actions of the route with the share of the ECS target group split between it and the K8s target group, the
+// other target groups of the forward (e.g. a canary) keep their weights
+func weightedActions(route trafficRoute, weight int32) []elbtypes.Action {
+	var actions []elbtypes.Action
+	for _, action := range route.Actions {
+		if action.Type == elbtypes.ActionTypeEnumForward && forwardsTo([]elbtypes.Action{action}, route.ECSTargetGroupArn) {
+			tuples := []elbtypes.TargetGroupTuple{{TargetGroupArn: action.TargetGroupArn}}
+			forward := &elbtypes.ForwardActionConfig{}
+			if action.ForwardConfig != nil {
+				tuples = action.ForwardConfig.TargetGroups
+				forward.TargetGroupStickinessConfig = action.ForwardConfig.TargetGroupStickinessConfig
+			}
+			for _, tuple := range tuples {
+				if tuple.TargetGroupArn == nil || *tuple.TargetGroupArn != route.ECSTargetGroupArn {
+					forward.TargetGroups = append(forward.TargetGroups, tuple)
+					continue
+				}
+				// A lone target group gets all the traffic whatever its weight
+				share := int32(100)
+				if len(tuples) > 1 && tuple.Weight != nil {
+					share = *tuple.Weight
+				}
+				k8sWeight := share * weight / 100
+				ecsWeight := share - k8sWeight
+				forward.TargetGroups = append(forward.TargetGroups,
+					elbtypes.TargetGroupTuple{TargetGroupArn: &route.ECSTargetGroupArn, Weight: &ecsWeight},
+					elbtypes.TargetGroupTuple{TargetGroupArn: &route.K8sTargetGroupArn, Weight: &k8sWeight},
+				)
+			}
+			action.TargetGroupArn = nil
+			action.ForwardConfig = forward
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+func setRouteActions(client *elb.Client, route trafficRoute, actions []elbtypes.Action) error {
+	var err error
+	if route.RuleArn != "" {
+		_, err = client.ModifyRule(context.TODO(), &elb.ModifyRuleInput{RuleArn: &route.RuleArn, Actions: actions})
+	} else {
+		_, err = client.ModifyListener(context.TODO(), &elb.ModifyListenerInput{ListenerArn: &route.ListenerArn, DefaultActions: actions})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to modify %s: %v", routeName(route), err)
+	}
+	return nil
+}
+
+func routeName(route trafficRoute) string {
+	if route.RuleArn != "" {
+		return "listener rule " + route.RuleArn
+	}
+	return "default actions of listener " + route.ListenerArn
+}
+
+// Waits up to --timeout for the target group to have targets, all of them healthy
+func awaitHealthyTargets(client *elb.Client, targetGroupArn string) bool {
+	fmt.Println("Waiting up to", rolloutTimeout, "for the targets of", targetGroupArn, "to become healthy...")
+	err := wait.PollImmediate(rolloutPollInterval, rolloutTimeout, func() (bool, error) {
+		return unhealthyTargets(client, targetGroupArn) == "", nil
+	})
+	if err != nil {
+		fmt.Println("Targets of", targetGroupArn, "did not become healthy:", unhealthyTargets(client, targetGroupArn))
+		return false
+	}
+	return true
+}
+
+// Describes the targets that are not healthy, empty when there are targets and all are healthy
+func unhealthyTargets(client *elb.Client, targetGroupArn string) string {
+	output, err := client.DescribeTargetHealth(context.TODO(), &elb.DescribeTargetHealthInput{TargetGroupArn: &targetGroupArn})
+	if err != nil {
+		return err.Error()
+	}
+	if len(output.TargetHealthDescriptions) == 0 {
+		return "no targets registered"
+	}
+
+	var unhealthy []string
+	for _, t := range output.TargetHealthDescriptions {
+		if t.TargetHealth.State != elbtypes.TargetHealthStateEnumHealthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s %s", *t.Target.Id, t.TargetHealth.State))
+		}
+	}
+	return strings.Join(unhealthy, ", ")
+}
+
+// Puts back the listener rules recorded before traffic was shifted
+func restoreTraffic(cluster string, service string) error {
+	record, err := loadTrafficRecord(cluster, service)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("no traffic shift of ECS service %s recorded in %s", service, filepath.Dir(trafficPath(cluster, service)))
+	}
+
+	if dryRun != dryRunNone {
+		for _, route := range record.Routes {
+			fmt.Println("Would restore", routeName(route), "(dry run)")
+		}
+		return nil
+	}
+
+	client, err := newELBClient()
+	if err != nil {
+		return err
+	}
+	for _, route := range record.Routes {
+		if err := setRouteActions(client, route, route.Actions); err != nil {
+			return err
+		}
+		fmt.Println("Restored", routeName(route))
+	}
+	if err := os.Remove(trafficPath(cluster, service)); err != nil {
+		fmt.Println("Unable to remove", trafficPath(cluster, service)+":", err)
+	}
+	fmt.Println("Traffic of ECS service", service, "restored")
+	return nil
+}
+
+// Returns nil when no traffic shift of the service is recorded
+func loadTrafficRecord(cluster string, service string) (*trafficRecord, error) {
+	bytes, err := ioutil.ReadFile(trafficPath(cluster, service))
+	if err != nil {
+		return nil, nil
+	}
+	var record trafficRecord
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", trafficPath(cluster, service), err)
+	}
+	return &record, nil
+}
+
+func saveTrafficRecord(record *trafficRecord) error {
+	path := trafficPath(record.Cluster, record.Service)
+	if err := createSpecDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	bytes, _ := json.MarshalIndent(record, "", "  ")
+	return writeSpecFile(path, bytes)
+}
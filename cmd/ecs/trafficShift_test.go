@@ -0,0 +1,101 @@
+package ecsCmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+func TestWeightedActions(t *testing.T) {
+	const ecsTargetGroup, k8sTargetGroup, otherTargetGroup = "arn:ecs", "arn:k8s", "arn:other"
+
+	weighted := func(ecsWeight int32, k8sWeight int32, stickiness *elbtypes.TargetGroupStickinessConfig) elbtypes.Action {
+		return elbtypes.Action{
+			Type: elbtypes.ActionTypeEnumForward,
+			ForwardConfig: &elbtypes.ForwardActionConfig{
+				TargetGroups: []elbtypes.TargetGroupTuple{
+					{TargetGroupArn: aws.String(ecsTargetGroup), Weight: aws.Int32(ecsWeight)},
+					{TargetGroupArn: aws.String(k8sTargetGroup), Weight: aws.Int32(k8sWeight)},
+				},
+				TargetGroupStickinessConfig: stickiness,
+			},
+		}
+	}
+	stickiness := &elbtypes.TargetGroupStickinessConfig{Enabled: aws.Bool(true), DurationSeconds: aws.Int32(60)}
+	authenticate := elbtypes.Action{Type: elbtypes.ActionTypeEnumAuthenticateCognito, Order: aws.Int32(1)}
+
+	tests := []struct {
+		name    string
+		actions []elbtypes.Action
+		weight  int32
+		want    []elbtypes.Action
+	}{
+		{
+			name:    "forward to the target group",
+			actions: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(ecsTargetGroup)}},
+			weight:  25,
+			want:    []elbtypes.Action{weighted(75, 25, nil)},
+		},
+		{
+			name:    "attached at weight 0",
+			actions: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(ecsTargetGroup)}},
+			weight:  0,
+			want:    []elbtypes.Action{weighted(100, 0, nil)},
+		},
+		{
+			name: "forward config keeps stickiness",
+			actions: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, ForwardConfig: &elbtypes.ForwardActionConfig{
+				TargetGroups:                []elbtypes.TargetGroupTuple{{TargetGroupArn: aws.String(ecsTargetGroup)}},
+				TargetGroupStickinessConfig: stickiness,
+			}}},
+			weight: 100,
+			want:   []elbtypes.Action{weighted(0, 100, stickiness)},
+		},
+		{
+			name: "other actions are kept",
+			actions: []elbtypes.Action{
+				authenticate,
+				{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(ecsTargetGroup), Order: aws.Int32(2)},
+			},
+			weight: 50,
+			want: []elbtypes.Action{
+				authenticate,
+				func() elbtypes.Action { a := weighted(50, 50, nil); a.Order = aws.Int32(2); return a }(),
+			},
+		},
+		{
+			name: "canary target group keeps its weight",
+			actions: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, ForwardConfig: &elbtypes.ForwardActionConfig{
+				TargetGroups: []elbtypes.TargetGroupTuple{
+					{TargetGroupArn: aws.String(ecsTargetGroup), Weight: aws.Int32(90)},
+					{TargetGroupArn: aws.String(otherTargetGroup), Weight: aws.Int32(10)},
+				},
+			}}},
+			weight: 50,
+			want: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, ForwardConfig: &elbtypes.ForwardActionConfig{
+				TargetGroups: []elbtypes.TargetGroupTuple{
+					{TargetGroupArn: aws.String(ecsTargetGroup), Weight: aws.Int32(45)},
+					{TargetGroupArn: aws.String(k8sTargetGroup), Weight: aws.Int32(45)},
+					{TargetGroupArn: aws.String(otherTargetGroup), Weight: aws.Int32(10)},
+				},
+			}}},
+		},
+		{
+			name:    "forward to another target group",
+			actions: []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(otherTargetGroup)}},
+			weight:  50,
+			want:    []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(otherTargetGroup)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := trafficRoute{ECSTargetGroupArn: ecsTargetGroup, K8sTargetGroupArn: k8sTargetGroup, Actions: tt.actions}
+			if got := weightedActions(route, tt.weight); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("weightedActions(%d) = %+v, want %+v", tt.weight, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package ecsCmd
+
+import (
+	"fmt"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	efsCSIDriver = "efs.csi.aws.com"
+	// EFS is elastic, the size is required by the API but ignored by the driver
+	defaultVolumeSize = "5Gi"
+)
+
+var persistentVolumes []corev1.PersistentVolume
+
+var persistentVolumeClaims []corev1.PersistentVolumeClaim
+
+// How host bind mounts are translated, "hostPath" or "emptyDir"
+var bindMountType string
+
+func validateBindMountType() error {
+	if bindMountType != "hostPath" && bindMountType != "emptyDir" {
+		return fmt.Errorf("invalid bind mount type %s - must be hostPath or emptyDir", bindMountType)
+	}
+	return nil
+}
+
+// Translates task level volumes into pod volumes, creating PVs and PVCs where needed
+func generateVolumes(output ecs.DescribeTaskDefinitionOutput, namespace string, apply bool) []corev1.Volume {
+	var kubeVolumes []corev1.Volume
+	family := *output.TaskDefinition.Family
+
+	for _, volume := range output.TaskDefinition.Volumes {
+		if volume.Name == nil {
+			continue
+		}
+		name := kubeName(*volume.Name)
+		kv := corev1.Volume{Name: name}
+
+		switch {
+		case volume.EfsVolumeConfiguration != nil:
+			claimName := kubeName(family + "-" + *volume.Name)
+			generateEFSVolume(claimName, volume.EfsVolumeConfiguration, namespace, apply)
+			kv.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}
+		case volume.DockerVolumeConfiguration != nil && volume.DockerVolumeConfiguration.Scope == types.ScopeShared:
+			// Shared Docker volumes outlive the task, the closest equivalent is a PVC
+			claimName := kubeName(family + "-" + *volume.Name)
+			generatePersistentVolumeClaim(claimName, "", namespace, corev1.ReadWriteOnce, apply)
+			kv.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}
+		case volume.FsxWindowsFileServerVolumeConfiguration != nil:
+			root.PrintWarning("FSx for Windows File Server volume", *volume.Name, "is not supported, using emptyDir")
+			kv.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		case volume.Host != nil && volume.Host.SourcePath != nil && bindMountType == "hostPath":
+			kv.HostPath = &corev1.HostPathVolumeSource{Path: *volume.Host.SourcePath}
+		default:
+			// Task scoped Docker volumes and bind mounts without a source path live as long as the task
+			kv.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		}
+
+		kubeVolumes = append(kubeVolumes, kv)
+	}
+
+	return kubeVolumes
+}
+
+// Translates container mount points, including those inherited through volumesFrom, into volume mounts
+func generateVolumeMounts(object types.ContainerDefinition, containers []types.ContainerDefinition) ([]corev1.VolumeMount, error) {
+	var volumeMounts []corev1.VolumeMount
+
+	for _, mp := range object.MountPoints {
+		volumeMounts = appendVolumeMount(volumeMounts, mp, mp.ReadOnly != nil && *mp.ReadOnly)
+	}
+
+	for _, vf := range object.VolumesFrom {
+		if vf.SourceContainer == nil {
+			continue
+		}
+		source, found := findContainerDefinition(*vf.SourceContainer, containers)
+		if !found {
+			return nil, fmt.Errorf("container %s referenced in volumesFrom of %s not found", *vf.SourceContainer, *object.Name)
+		}
+		for _, mp := range source.MountPoints {
+			readOnly := (mp.ReadOnly != nil && *mp.ReadOnly) || (vf.ReadOnly != nil && *vf.ReadOnly)
+			volumeMounts = appendVolumeMount(volumeMounts, mp, readOnly)
+		}
+	}
+
+	return volumeMounts, nil
+}
+
+func appendVolumeMount(volumeMounts []corev1.VolumeMount, mp types.MountPoint, readOnly bool) []corev1.VolumeMount {
+	if mp.SourceVolume == nil || mp.ContainerPath == nil {
+		return volumeMounts
+	}
+	// A container path can only be mounted once, the container's own mount point wins over volumesFrom
+	for _, vm := range volumeMounts {
+		if vm.MountPath == *mp.ContainerPath {
+			return volumeMounts
+		}
+	}
+	return append(volumeMounts, corev1.VolumeMount{
+		Name:      kubeName(*mp.SourceVolume),
+		MountPath: *mp.ContainerPath,
+		ReadOnly:  readOnly,
+	})
+}
+
+func findContainerDefinition(name string, containers []types.ContainerDefinition) (types.ContainerDefinition, bool) {
+	for _, c := range containers {
+		if c.Name != nil && *c.Name == name {
+			return c, true
+		}
+	}
+	return types.ContainerDefinition{}, false
+}
+
+// Generates a statically provisioned PV for the EFS CSI driver and a PVC bound to it
+func generateEFSVolume(name string, efs *types.EFSVolumeConfiguration, namespace string, apply bool) {
+	// Volume handle format is [FileSystemId]:[Subpath]:[AccessPointId]
+	volumeHandle := *efs.FileSystemId
+	if efs.AuthorizationConfig != nil && efs.AuthorizationConfig.AccessPointId != nil {
+		// The access point defines the root directory when it is used
+		volumeHandle = volumeHandle + "::" + *efs.AuthorizationConfig.AccessPointId
+	} else if efs.RootDirectory != nil && *efs.RootDirectory != "" && *efs.RootDirectory != "/" {
+		volumeHandle = volumeHandle + ":" + *efs.RootDirectory
+	}
+
+	var mountOptions []string
+	if efs.TransitEncryption == types.EFSTransitEncryptionEnabled {
+		mountOptions = append(mountOptions, "tls")
+	}
+	if efs.AuthorizationConfig != nil && efs.AuthorizationConfig.Iam == types.EFSAuthorizationConfigIAMEnabled {
+		mountOptions = append(mountOptions, "iam")
+	}
+
+	// PVs are cluster scoped, the namespace keeps the same volume name of different namespaces apart
+	pvName := kubeName(namespace + "-" + name)
+	pv := corev1.PersistentVolume{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "PersistentVolume"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pvName,
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(defaultVolumeSize),
+			},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			MountOptions:                  mountOptions,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       efsCSIDriver,
+					VolumeHandle: volumeHandle,
+				},
+			},
+		},
+	}
+
+	if !persistentVolumeExists(pvName) {
+		if apply {
+			applyKubePersistentVolume(&pv)
+		}
+		persistentVolumes = append(persistentVolumes, pv)
+	}
+
+	generatePersistentVolumeClaim(name, pvName, namespace, corev1.ReadWriteMany, apply)
+}
+
+// Generates a PVC, bound to the named PV if one is given, otherwise provisioned by the default storage class
+func generatePersistentVolumeClaim(name string, volumeName string, namespace string, accessMode corev1.PersistentVolumeAccessMode, apply bool) {
+	for i := range persistentVolumeClaims {
+		if persistentVolumeClaims[i].ObjectMeta.Name == name {
+			return
+		}
+	}
+
+	pvc := corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{accessMode},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(defaultVolumeSize),
+				},
+			},
+		},
+	}
+
+	if volumeName != "" {
+		// Empty storage class disables dynamic provisioning so the claim binds to the static PV
+		storageClassName := ""
+		pvc.Spec.StorageClassName = &storageClassName
+		pvc.Spec.VolumeName = volumeName
+	}
+
+	if apply {
+		applyKubePersistentVolumeClaim(&pvc)
+	}
+	persistentVolumeClaims = append(persistentVolumeClaims, pvc)
+}
+
+func persistentVolumeExists(name string) bool {
+	for i := range persistentVolumes {
+		if persistentVolumes[i].ObjectMeta.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,678 @@
+package k8sCmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	root "codaglobal/ecs2k8s/cmd/root"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	gyaml "github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	launchTypeFargate = "FARGATE"
+	launchTypeEC2     = "EC2"
+)
+
+// Fargate task sizes, CPU units and the memory in MiB allowed with them, either listed or from min to max by step
+var fargateSizes = []struct {
+	cpu       int64
+	memories  []int64
+	minMemory int64
+	maxMemory int64
+	step      int64
+}{
+	{cpu: 256, memories: []int64{512, 1024, 2048}},
+	{cpu: 512, minMemory: 1024, maxMemory: 4096, step: 1024},
+	{cpu: 1024, minMemory: 2048, maxMemory: 8192, step: 1024},
+	{cpu: 2048, minMemory: 4096, maxMemory: 16384, step: 1024},
+	{cpu: 4096, minMemory: 8192, maxMemory: 30720, step: 1024},
+	{cpu: 8192, minMemory: 16384, maxMemory: 61440, step: 4096},
+	{cpu: 16384, minMemory: 32768, maxMemory: 122880, step: 8192},
+}
+
+// Client of the K8s cluster the deployment is read from, nil when it is read from a file
+var kubeClient *kubernetes.Clientset
+
+// Secrets Manager or SSM parameter ARNs of the K8s secrets, keyed by <secret>/<key> or <secret>
+var secretsMapping map[string]string
+
+// How the deployment is converted
+var (
+	launchType       string
+	executionRoleArn string
+	taskRoleArn      string
+)
+
+// generateEcsTaskCmd represents the generate-ecs-task command
+var generateEcsTaskCmd = &cobra.Command{
+	Use:   "generate-ecs-task",
+	Short: "Generate an ECS task definition from a K8s deployment",
+	Long: `Generate the input of ECS RegisterTaskDefinition from a K8s deployment, read from the cluster or from a file.
+Containers, ports, environment variables, secrets, resources, health checks and volumes are carried over. Secret
+references are mapped to Secrets Manager or SSM parameter ARNs with --secrets-mapping. For example:
+
+	ecs2k8s k8s generate-ecs-task --deployment xxxx --namespace xxxx --secrets-mapping secrets.yaml
+	aws ecs register-task-definition --cli-input-json file://xxxx.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fileName, _ := cmd.Flags().GetString("file-name")
+		yaml, _ := cmd.Flags().GetBool("yaml")
+
+		deployment := loadDeployment(cmd)
+		input := generateTaskDefinition(deployment)
+
+		if fileName == "" {
+			fileName = fileSafeName(*input.Family) + "-task-definition"
+			if yaml {
+				fileName += ".yaml"
+			} else {
+				fileName += ".json"
+			}
+		}
+
+		data, _ := json.MarshalIndent(cliInput(input), "", "  ")
+		if yaml {
+			data, _ = gyaml.JSONToYAML(data)
+		} else {
+			data = append(data, '\n')
+		}
+
+		if fileName == root.StdoutFileName {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+			fmt.Println("Unable to write", fileName+":", err)
+			os.Exit(1)
+		}
+		fmt.Println("Task definition written to", fileName)
+	},
+}
+
+func init() {
+	k8sCmd.AddCommand(generateEcsTaskCmd)
+}
+
+// Reads the flags of the conversion and the deployment, from --from-file or from the cluster
+func loadDeployment(cmd *cobra.Command) appsv1.Deployment {
+	name, _ := cmd.Flags().GetString("deployment")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	fromFile, _ := cmd.Flags().GetString("from-file")
+	mappingFile, _ := cmd.Flags().GetString("secrets-mapping")
+	containerName, _ := cmd.Flags().GetString("container-name")
+	launchType, _ = cmd.Flags().GetString("launch-type")
+	executionRoleArn, _ = cmd.Flags().GetString("execution-role-arn")
+	taskRoleArn, _ = cmd.Flags().GetString("task-role-arn")
+	root.ReadKubeFlags(cmd)
+
+	launchType = strings.ToUpper(launchType)
+	if launchType != launchTypeFargate && launchType != launchTypeEC2 {
+		fmt.Println("Invalid launch type", launchType, "- must be", launchTypeFargate, "or", launchTypeEC2)
+		os.Exit(1)
+	}
+
+	secretsMapping = make(map[string]string)
+	if mappingFile != "" {
+		data, err := ioutil.ReadFile(mappingFile)
+		if err != nil {
+			fmt.Println("Unable to read", mappingFile+":", err)
+			os.Exit(1)
+		}
+		if err := gyaml.Unmarshal(data, &secretsMapping); err != nil {
+			fmt.Println("Invalid secrets mapping in", mappingFile+":", err)
+			os.Exit(1)
+		}
+	}
+
+	var deployment appsv1.Deployment
+	if fromFile != "" {
+		deployment = readDeploymentFromFile(fromFile, name)
+	} else {
+		if name == "" || namespace == "" {
+			fmt.Println("Deployment and namespace, or --from-file required")
+			os.Exit(1)
+		}
+
+		config, err := root.KubeConfig()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		kubeClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			fmt.Println("Unable to create the K8s client:", err)
+			os.Exit(1)
+		}
+		d, err := kubeClient.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			fmt.Println("Unable to get deployment", name+":", err)
+			os.Exit(1)
+		}
+		deployment = *d
+	}
+
+	if containerName != "" {
+		var containers []corev1.Container
+		for _, c := range deployment.Spec.Template.Spec.Containers {
+			if c.Name == containerName {
+				containers = append(containers, c)
+			}
+		}
+		if len(containers) == 0 {
+			fmt.Println("Container", containerName, "not found in deployment", deployment.Name)
+			os.Exit(1)
+		}
+		deployment.Spec.Template.Spec.Containers = containers
+	}
+
+	return deployment
+}
+
+// Reads a deployment from a YAML or JSON file holding a Deployment or a List of objects
+func readDeploymentFromFile(path string, name string) appsv1.Deployment {
+	var data []byte
+	var err error
+	if path == root.StdoutFileName {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Println("Unable to read", path+":", err)
+		os.Exit(1)
+	}
+
+	var list struct {
+		metav1.TypeMeta `json:",inline"`
+		Items           []json.RawMessage `json:"items"`
+	}
+	if err := gyaml.Unmarshal(data, &list); err != nil {
+		fmt.Println("Invalid deployment in", path+":", err)
+		os.Exit(1)
+	}
+
+	items := list.Items
+	if list.Kind != "List" {
+		document, _ := gyaml.YAMLToJSON(data)
+		items = append(items, document)
+	}
+
+	for _, item := range items {
+		var d appsv1.Deployment
+		if err := json.Unmarshal(item, &d); err != nil || d.Kind != "Deployment" {
+			continue
+		}
+		if name == "" || d.Name == name {
+			return d
+		}
+	}
+
+	fmt.Println("No deployment", name, "found in", path)
+	os.Exit(1)
+	return appsv1.Deployment{}
+}
+
+// Translates a deployment into the input of RegisterTaskDefinition
+func generateTaskDefinition(d appsv1.Deployment) ecs.RegisterTaskDefinitionInput {
+	podSpec := d.Spec.Template.Spec
+	family := d.Name
+
+	input := ecs.RegisterTaskDefinitionInput{
+		Family:                  &family,
+		NetworkMode:             types.NetworkModeAwsvpc,
+		RequiresCompatibilities: []types.Compatibility{types.Compatibility(launchType)},
+	}
+	if executionRoleArn != "" {
+		input.ExecutionRoleArn = &executionRoleArn
+	}
+	if taskRoleArn != "" {
+		input.TaskRoleArn = &taskRoleArn
+	} else if role := serviceAccountRole(d.Namespace, podSpec.ServiceAccountName); role != "" {
+		input.TaskRoleArn = &role
+	}
+
+	// ECS reserves the resources of every container, init containers included, for the whole task
+	var taskCpu, taskMemory int64
+	addContainer := func(cd types.ContainerDefinition) {
+		input.ContainerDefinitions = append(input.ContainerDefinitions, cd)
+		taskCpu += int64(cd.Cpu)
+		if cd.Memory != nil {
+			taskMemory += int64(*cd.Memory)
+		} else if cd.MemoryReservation != nil {
+			taskMemory += int64(*cd.MemoryReservation)
+		}
+	}
+
+	// Init containers run to completion one after the other. Native sidecars (restartPolicy Always) keep running, the
+	// containers after them only wait for them to start, or to be healthy when they have a startup or readiness probe.
+	var lastInit *types.ContainerDependency
+	var sidecars []types.ContainerDependency
+	waitFor := func() []types.ContainerDependency {
+		deps := append([]types.ContainerDependency{}, sidecars...)
+		if lastInit != nil {
+			deps = append(deps, *lastInit)
+		}
+		return deps
+	}
+	for _, c := range podSpec.InitContainers {
+		cd := generateContainerDefinition(c, d.Namespace)
+		cd.DependsOn = waitFor()
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			condition := types.ContainerConditionStart
+			if cd.HealthCheck != nil && (c.StartupProbe != nil || c.ReadinessProbe != nil) {
+				condition = types.ContainerConditionHealthy
+			}
+			sidecars = append(sidecars, types.ContainerDependency{ContainerName: cd.Name, Condition: condition})
+		} else {
+			essential := false
+			cd.Essential = &essential
+			lastInit = &types.ContainerDependency{ContainerName: cd.Name, Condition: types.ContainerConditionSuccess}
+		}
+		addContainer(cd)
+	}
+
+	for _, c := range podSpec.Containers {
+		cd := generateContainerDefinition(c, d.Namespace)
+		cd.DependsOn = waitFor()
+		addContainer(cd)
+	}
+
+	input.Volumes = generateVolumes(podSpec.Volumes)
+
+	if launchType == launchTypeFargate {
+		cpu, memory, err := fargateSize(taskCpu, taskMemory)
+		if err != nil {
+			fmt.Println("Unable to size the Fargate task of deployment", d.Name+":", err)
+			os.Exit(1)
+		}
+		input.Cpu, input.Memory = &cpu, &memory
+	}
+
+	if len(podSpec.NodeSelector) > 0 || podSpec.Affinity != nil || len(podSpec.Tolerations) > 0 {
+		root.PrintWarning("Node selectors, affinity and tolerations of deployment", d.Name, "are not converted")
+	}
+	if hasSecrets(input.ContainerDefinitions) && input.ExecutionRoleArn == nil {
+		root.PrintWarning("Containers reference secrets, ECS needs --execution-role-arn to read them")
+	}
+
+	return input
+}
+
+// The IRSA role of the service account, when the deployment is read from the cluster
+func serviceAccountRole(namespace string, name string) string {
+	if kubeClient == nil || name == "" {
+		return ""
+	}
+	sa, err := kubeClient.CoreV1().ServiceAccounts(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return sa.Annotations[root.IRSARoleAnnotation]
+}
+
+func generateContainerDefinition(c corev1.Container, namespace string) types.ContainerDefinition {
+	name, image := c.Name, c.Image
+	essential := true
+	cd := types.ContainerDefinition{
+		Name:       &name,
+		Image:      &image,
+		Essential:  &essential,
+		EntryPoint: c.Command,
+		Command:    c.Args,
+	}
+	if c.WorkingDir != "" {
+		workingDir := c.WorkingDir
+		cd.WorkingDirectory = &workingDir
+	}
+
+	for _, p := range c.Ports {
+		containerPort := p.ContainerPort
+		cd.PortMappings = append(cd.PortMappings, types.PortMapping{
+			ContainerPort: &containerPort,
+			Protocol:      types.TransportProtocol(strings.ToLower(string(p.Protocol))),
+		})
+	}
+
+	// The reverse of generateDeploymentObject, which takes CPU units as millicores
+	if cpu, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+		cd.Cpu = int32(cpu.MilliValue())
+	} else if cpu, ok := c.Resources.Limits[corev1.ResourceCPU]; ok {
+		cd.Cpu = int32(cpu.MilliValue())
+	}
+	if memory, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+		mib := int32(memory.Value() / (1024 * 1024))
+		cd.Memory = &mib
+	}
+	if memory, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+		mib := int32(memory.Value() / (1024 * 1024))
+		cd.MemoryReservation = &mib
+	}
+
+	generateEnvironment(&cd, c, namespace)
+	cd.HealthCheck = generateHealthCheck(c)
+
+	for _, m := range c.VolumeMounts {
+		sourceVolume, containerPath, readOnly := m.Name, m.MountPath, m.ReadOnly
+		cd.MountPoints = append(cd.MountPoints, types.MountPoint{
+			SourceVolume:  &sourceVolume,
+			ContainerPath: &containerPath,
+			ReadOnly:      &readOnly,
+		})
+	}
+
+	return cd
+}
+
+// Translates env and envFrom into environment variables and secrets
+func generateEnvironment(cd *types.ContainerDefinition, c corev1.Container, namespace string) {
+	addValue := func(name string, value string) {
+		cd.Environment = append(cd.Environment, types.KeyValuePair{Name: &name, Value: &value})
+	}
+	addSecret := func(name string, secret string, key string) {
+		valueFrom, ok := secretArn(secret, key)
+		if !ok {
+			root.PrintWarning("No ARN for key", key, "of secret", secret, "in --secrets-mapping, skipping", name)
+			return
+		}
+		cd.Secrets = append(cd.Secrets, types.Secret{Name: &name, ValueFrom: &valueFrom})
+	}
+
+	for _, from := range c.EnvFrom {
+		switch {
+		case from.ConfigMapRef != nil:
+			for key, value := range configMapData(namespace, from.ConfigMapRef.Name) {
+				addValue(from.Prefix+key, value)
+			}
+		case from.SecretRef != nil:
+			for _, key := range secretKeys(namespace, from.SecretRef.Name) {
+				addSecret(from.Prefix+key, from.SecretRef.Name, key)
+			}
+		}
+	}
+
+	for _, env := range c.Env {
+		switch {
+		case env.ValueFrom == nil:
+			addValue(env.Name, env.Value)
+		case env.ValueFrom.SecretKeyRef != nil:
+			addSecret(env.Name, env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Key)
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			value, ok := configMapData(namespace, env.ValueFrom.ConfigMapKeyRef.Name)[env.ValueFrom.ConfigMapKeyRef.Key]
+			if !ok {
+				root.PrintWarning("Unable to read key", env.ValueFrom.ConfigMapKeyRef.Key, "of config map", env.ValueFrom.ConfigMapKeyRef.Name+", skipping", env.Name)
+				continue
+			}
+			addValue(env.Name, value)
+		default:
+			root.PrintWarning("Environment variable", env.Name, "of container", c.Name, "uses a field or resource reference, skipping")
+		}
+	}
+
+	sort.SliceStable(cd.Environment, func(i, j int) bool { return *cd.Environment[i].Name < *cd.Environment[j].Name })
+}
+
+// ARN a secret key is read from, a whole Secrets Manager secret mapped to a K8s secret is read by JSON key
+func secretArn(secret string, key string) (string, bool) {
+	if arn, ok := secretsMapping[secret+"/"+key]; ok {
+		return arn, true
+	}
+	if arn, ok := secretsMapping[secret]; ok {
+		return arn + ":" + key + "::", true
+	}
+	return "", false
+}
+
+// Data of a config map, when the deployment is read from the cluster
+func configMapData(namespace string, name string) map[string]string {
+	if kubeClient == nil {
+		root.PrintWarning("Config map", name, "can only be read from the cluster, skipping its values")
+		return nil
+	}
+	cm, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		root.PrintWarning("Unable to read config map", name+":", err)
+		return nil
+	}
+	return cm.Data
+}
+
+// Keys of a secret, when the deployment is read from the cluster
+func secretKeys(namespace string, name string) []string {
+	if kubeClient == nil {
+		root.PrintWarning("Keys of secret", name, "can only be read from the cluster, skipping it")
+		return nil
+	}
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		root.PrintWarning("Unable to read secret", name+":", err)
+		return nil
+	}
+	var keys []string
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Translates the liveness probe, or the readiness probe, into an ECS health check
+func generateHealthCheck(c corev1.Container) *types.HealthCheck {
+	probe := c.LivenessProbe
+	if probe == nil {
+		probe = c.ReadinessProbe
+	}
+	if probe == nil {
+		return nil
+	}
+
+	var command []string
+	switch {
+	case probe.Exec != nil:
+		if len(probe.Exec.Command) == 3 && (probe.Exec.Command[0] == "/bin/sh" || probe.Exec.Command[0] == "sh") && probe.Exec.Command[1] == "-c" {
+			command = []string{"CMD-SHELL", probe.Exec.Command[2]}
+		} else {
+			command = append([]string{"CMD"}, probe.Exec.Command...)
+		}
+	case probe.HTTPGet != nil:
+		scheme := strings.ToLower(string(probe.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		port := probePort(c, probe.HTTPGet.Port.String())
+		command = []string{"CMD-SHELL", fmt.Sprintf("curl -f %s://localhost:%s%s || exit 1", scheme, port, probe.HTTPGet.Path)}
+	case probe.TCPSocket != nil:
+		command = []string{"CMD-SHELL", fmt.Sprintf("nc -z localhost %s || exit 1", probePort(c, probe.TCPSocket.Port.String()))}
+	default:
+		root.PrintWarning("Probe of container", c.Name, "cannot be expressed as an ECS health check, skipping")
+		return nil
+	}
+
+	// ECS accepts intervals of 5 to 300 and timeouts of 2 to 60 seconds
+	interval := clamp(valueOrDefault(probe.PeriodSeconds, 10), 5, 300)
+	timeout := clamp(valueOrDefault(probe.TimeoutSeconds, 1), 2, 60)
+	retries := clamp(valueOrDefault(probe.FailureThreshold, 3), 1, 10)
+	healthCheck := &types.HealthCheck{
+		Command:  command,
+		Interval: &interval,
+		Timeout:  &timeout,
+		Retries:  &retries,
+	}
+
+	startPeriod := probe.InitialDelaySeconds
+	if s := c.StartupProbe; s != nil {
+		startPeriod += s.InitialDelaySeconds + valueOrDefault(s.PeriodSeconds, 10)*valueOrDefault(s.FailureThreshold, 3)
+	}
+	if startPeriod > 0 {
+		startPeriod = clamp(startPeriod, 0, 300)
+		healthCheck.StartPeriod = &startPeriod
+	}
+
+	return healthCheck
+}
+
+// Resolves a named port of the container
+func probePort(c corev1.Container, port string) string {
+	for _, p := range c.Ports {
+		if p.Name == port {
+			return strconv.Itoa(int(p.ContainerPort))
+		}
+	}
+	return port
+}
+
+// Translates emptyDir and hostPath volumes, the others have no ECS counterpart
+func generateVolumes(volumes []corev1.Volume) []types.Volume {
+	var result []types.Volume
+	for _, v := range volumes {
+		name := v.Name
+		switch {
+		case v.EmptyDir != nil:
+			result = append(result, types.Volume{Name: &name})
+		case v.HostPath != nil:
+			if launchType == launchTypeFargate {
+				root.PrintWarning("Host path volume", name, "is not supported on Fargate, using an ephemeral volume")
+				result = append(result, types.Volume{Name: &name})
+				continue
+			}
+			path := v.HostPath.Path
+			result = append(result, types.Volume{Name: &name, Host: &types.HostVolumeProperties{SourcePath: &path}})
+		default:
+			root.PrintWarning("Volume", name, "is not an emptyDir or hostPath volume, using an ephemeral volume")
+			result = append(result, types.Volume{Name: &name})
+		}
+	}
+	return result
+}
+
+// Smallest Fargate task size that fits the CPU units and memory of the containers
+func fargateSize(cpu int64, memory int64) (string, string, error) {
+	for _, size := range fargateSizes {
+		if cpu > size.cpu {
+			continue
+		}
+		for _, m := range size.memories {
+			if m >= memory {
+				return strconv.FormatInt(size.cpu, 10), strconv.FormatInt(m, 10), nil
+			}
+		}
+		if size.step == 0 || memory > size.maxMemory {
+			continue
+		}
+		m := size.minMemory
+		for m < memory {
+			m += size.step
+		}
+		return strconv.FormatInt(size.cpu, 10), strconv.FormatInt(m, 10), nil
+	}
+	return "", "", fmt.Errorf("containers need %d CPU units and %d MiB, more than the largest Fargate task size of 16 vCPU and 120 GB", cpu, memory)
+}
+
+func hasSecrets(containers []types.ContainerDefinition) bool {
+	for _, c := range containers {
+		if len(c.Secrets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// K8s defaults probe fields left at 0
+func valueOrDefault(value int32, defaultValue int32) int32 {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func clamp(value int32, min int32, max int32) int32 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// The task definition as accepted by aws ecs register-task-definition --cli-input-json, camelCase without empty fields
+func cliInput(input ecs.RegisterTaskDefinitionInput) interface{} {
+	data, _ := json.Marshal(input)
+	var value interface{}
+	json.Unmarshal(data, &value)
+	return camelCaseKeys(value, false)
+}
+
+// Keys of these maps are user data rather than field names
+var freeFormFields = map[string]bool{"DockerLabels": true, "Options": true, "DriverOpts": true, "Labels": true}
+
+// Fields kept when empty in the items of these lists, an environment variable can be set to ""
+var keepEmptyFields = map[string]string{"Environment": "Value"}
+
+func camelCaseKeys(value interface{}, freeForm bool) interface{} {
+	return camelCaseItem(value, freeForm, "")
+}
+
+func camelCaseItem(value interface{}, freeForm bool, keepEmpty string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for key, item := range v {
+			item = camelCaseItem(item, freeFormFields[key], keepEmptyFields[key])
+			if isEmpty(item) && key != keepEmpty {
+				continue
+			}
+			if !freeForm {
+				key = strings.ToLower(key[:1]) + key[1:]
+			}
+			result[key] = item
+		}
+		return result
+	case []interface{}:
+		var result []interface{}
+		for _, item := range v {
+			result = append(result, camelCaseItem(item, false, keepEmpty))
+		}
+		return result
+	}
+	return value
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// Utility function to convert a string into a file name
+func fileSafeName(name string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name), "-")
+}
@@ -0,0 +1,95 @@
+package k8sCmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFargateSize(t *testing.T) {
+	tests := []struct {
+		cpu        int64
+		memory     int64
+		wantCpu    string
+		wantMemory string
+		wantErr    bool
+	}{
+		{0, 0, "256", "512", false},
+		{100, 300, "256", "512", false},
+		{256, 600, "256", "1024", false},
+		{256, 1100, "256", "2048", false},
+		{256, 2048, "256", "2048", false},
+		{256, 2100, "512", "3072", false},
+		{300, 512, "512", "1024", false},
+		{512, 4097, "1024", "5120", false},
+		{1000, 9000, "2048", "9216", false},
+		{4096, 30720, "4096", "30720", false},
+		{4096, 30721, "8192", "32768", false},
+		{8192, 1024, "8192", "16384", false},
+		{8192, 61441, "16384", "65536", false},
+		{16384, 122880, "16384", "122880", false},
+		{16385, 1024, "", "", true},
+		{1024, 122881, "", "", true},
+	}
+
+	for _, tt := range tests {
+		cpu, memory, err := fargateSize(tt.cpu, tt.memory)
+		if cpu != tt.wantCpu || memory != tt.wantMemory || (err != nil) != tt.wantErr {
+			t.Errorf("fargateSize(%d, %d) = %s, %s, %v, want %s, %s", tt.cpu, tt.memory, cpu, memory, err, tt.wantCpu, tt.wantMemory)
+		}
+	}
+}
+
+func TestGenerateTaskDefinitionDependencies(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}
+
+	var d appsv1.Deployment
+	d.Name = "web"
+	d.Spec.Template.Spec.InitContainers = []corev1.Container{
+		{Name: "fetch", Image: "busybox"},
+		{Name: "migrate", Image: "busybox"},
+		{Name: "log", Image: "fluent-bit", RestartPolicy: &always},
+		{Name: "proxy", Image: "envoy", RestartPolicy: &always, ReadinessProbe: probe},
+	}
+	d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "app", Image: "nginx"}}
+
+	dependency := func(name string, condition types.ContainerCondition) types.ContainerDependency {
+		return types.ContainerDependency{ContainerName: &name, Condition: condition}
+	}
+	tests := []struct {
+		name      string
+		essential bool
+		dependsOn []types.ContainerDependency
+	}{
+		{"fetch", false, []types.ContainerDependency{}},
+		{"migrate", false, []types.ContainerDependency{dependency("fetch", types.ContainerConditionSuccess)}},
+		{"log", true, []types.ContainerDependency{dependency("migrate", types.ContainerConditionSuccess)}},
+		{"proxy", true, []types.ContainerDependency{
+			dependency("log", types.ContainerConditionStart),
+			dependency("migrate", types.ContainerConditionSuccess),
+		}},
+		{"app", true, []types.ContainerDependency{
+			dependency("log", types.ContainerConditionStart),
+			dependency("proxy", types.ContainerConditionHealthy),
+			dependency("migrate", types.ContainerConditionSuccess),
+		}},
+	}
+
+	input := generateTaskDefinition(d)
+	if len(input.ContainerDefinitions) != len(tests) {
+		t.Fatalf("%d container definitions, want %d", len(input.ContainerDefinitions), len(tests))
+	}
+	for i, tt := range tests {
+		cd := input.ContainerDefinitions[i]
+		if *cd.Name != tt.name || *cd.Essential != tt.essential {
+			t.Errorf("container %d = %s essential %v, want %s essential %v", i, *cd.Name, *cd.Essential, tt.name, tt.essential)
+		}
+		if !reflect.DeepEqual(cd.DependsOn, tt.dependsOn) {
+			t.Errorf("dependencies of %s = %+v, want %+v", tt.name, cd.DependsOn, tt.dependsOn)
+		}
+	}
+}
@@ -39,8 +39,19 @@ func init() {
 	k8sCmd.PersistentFlags().String("deployment", "", "A valid deployment in K8s")
 	k8sCmd.PersistentFlags().String("container-name", "", "Name of the container inside the task, if more than one container is specified in that task")
 	k8sCmd.PersistentFlags().StringP("namespace", "n", "", "The Kubernetes namespace in which the deployment needs to be created")
-	k8sCmd.PersistentFlags().String("file-name", "", "The file into which K8s spec will be written to, defaults to datetime of spec generation")
+	k8sCmd.PersistentFlags().String("file-name", "", "The file into which the ECS task definition will be written to, defaults to <deployment>-task-definition.json, - writes to stdout")
 	k8sCmd.PersistentFlags().Bool("yaml", false, "Set this flag if spec file needs to generated in YAML, defaults to JSON")
-	k8sCmd.PersistentFlags().String("kubeconfig", "", "Config file for the K8s cluster, if parameter is not passed, checks $HOME/.kube directory and then KUBECONFIG environment variable")
-	k8sCmd.PersistentFlags().Int32("replicas", 1, "The replica count for the K8s deployment")
+	root.AddKubeFlags(k8sCmd)
+	k8sCmd.PersistentFlags().Int32("replicas", 1, "The desired count of the ECS service, defaults to the replicas of the deployment")
+	k8sCmd.PersistentFlags().String("from-file", "", "Read the deployment from a YAML or JSON file holding a Deployment or a List, or stdin (-), instead of the cluster")
+	k8sCmd.PersistentFlags().String("secrets-mapping", "", "YAML or JSON file mapping K8s secrets (<secret>) or secret keys (<secret>/<key>) to Secrets Manager or SSM parameter ARNs")
+	k8sCmd.PersistentFlags().String("launch-type", "FARGATE", "Launch type the task definition is made for, FARGATE or EC2")
+	k8sCmd.PersistentFlags().String("execution-role-arn", "", "Task execution role, needed to pull private images and read secrets")
+	k8sCmd.PersistentFlags().String("task-role-arn", "", "Task role, defaults to the IRSA role of the service account of the deployment")
+	k8sCmd.PersistentFlags().String("cluster", "", "The ECS cluster the service is created in")
+	k8sCmd.PersistentFlags().String("service", "", "Name of the ECS service to create, defaults to the name of the deployment")
+	k8sCmd.PersistentFlags().Bool("create-service", false, "Create an ECS service running the registered task definition")
+	k8sCmd.PersistentFlags().String("subnets", "", "Comma separated subnets of the ECS service")
+	k8sCmd.PersistentFlags().String("security-groups", "", "Comma separated security groups of the ECS service")
+	k8sCmd.PersistentFlags().Bool("assign-public-ip", false, "Assign a public IP to the tasks of the ECS service")
 }
@@ -0,0 +1,165 @@
+package k8sCmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// migrateDeploymentCmd represents the migrate-deployment command
+var migrateDeploymentCmd = &cobra.Command{
+	Use:   "migrate-deployment",
+	Short: "Migrate a K8s deployment to ECS.",
+	Long: `Registers an ECS task definition generated from a K8s deployment, and with --create-service creates an ECS service
+running it with the replicas of the deployment. For example:
+
+	ecs2k8s k8s migrate-deployment --deployment xxxx --namespace xxxx --execution-role-arn xxxx
+	ecs2k8s k8s migrate-deployment --deployment xxxx --namespace xxxx --create-service --cluster xxxx --subnets xxxx --security-groups xxxx`,
+	Run: func(cmd *cobra.Command, args []string) {
+		createService, _ := cmd.Flags().GetBool("create-service")
+		cluster, _ := cmd.Flags().GetString("cluster")
+		service, _ := cmd.Flags().GetString("service")
+		subnets, _ := cmd.Flags().GetString("subnets")
+		securityGroups, _ := cmd.Flags().GetString("security-groups")
+		assignPublicIP, _ := cmd.Flags().GetBool("assign-public-ip")
+		replicas, _ := cmd.Flags().GetInt32("replicas")
+
+		if createService && (cluster == "" || subnets == "") {
+			fmt.Println("Cluster and subnets required to create a service")
+			os.Exit(1)
+		}
+
+		deployment := loadDeployment(cmd)
+		deploymentConfig, err := deploymentConfiguration(deployment)
+		if err != nil {
+			fmt.Println("Unable to translate the rolling update strategy of deployment", deployment.Name+":", err)
+			os.Exit(1)
+		}
+		input := generateTaskDefinition(deployment)
+
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		client := ecs.NewFromConfig(cfg)
+
+		output, err := client.RegisterTaskDefinition(context.TODO(), &input)
+		if err != nil {
+			fmt.Println("Unable to register task definition", *input.Family+":", err)
+			os.Exit(1)
+		}
+		taskDefinitionArn := *output.TaskDefinition.TaskDefinitionArn
+		fmt.Println("Registered task definition", taskDefinitionArn)
+
+		if !createService {
+			return
+		}
+
+		if service == "" {
+			service = deployment.Name
+		}
+		desiredCount := deploymentReplicas(deployment)
+		if cmd.Flags().Changed("replicas") {
+			desiredCount = replicas
+		}
+
+		publicIP := types.AssignPublicIpDisabled
+		if assignPublicIP {
+			publicIP = types.AssignPublicIpEnabled
+		}
+
+		serviceInput := &ecs.CreateServiceInput{
+			Cluster:        &cluster,
+			ServiceName:    &service,
+			TaskDefinition: &taskDefinitionArn,
+			DesiredCount:   &desiredCount,
+			LaunchType:     types.LaunchType(launchType),
+			NetworkConfiguration: &types.NetworkConfiguration{
+				AwsvpcConfiguration: &types.AwsVpcConfiguration{
+					Subnets:        splitList(subnets),
+					SecurityGroups: splitList(securityGroups),
+					AssignPublicIp: publicIP,
+				},
+			},
+			DeploymentConfiguration: deploymentConfig,
+		}
+
+		if _, err := client.CreateService(context.TODO(), serviceInput); err != nil {
+			fmt.Println("Unable to create service", service+":", err)
+			os.Exit(1)
+		}
+		fmt.Println("Created service", service, "in ECS cluster", cluster, "with", desiredCount, "tasks")
+	},
+}
+
+func init() {
+	k8sCmd.AddCommand(migrateDeploymentCmd)
+}
+
+func deploymentReplicas(d appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// Translates the rolling update strategy into ECS maximum and minimum healthy percent
+func deploymentConfiguration(d appsv1.Deployment) (*types.DeploymentConfiguration, error) {
+	rollingUpdate := d.Spec.Strategy.RollingUpdate
+	if d.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		maxPercent, minHealthyPercent := int32(100), int32(0)
+		return &types.DeploymentConfiguration{MaximumPercent: &maxPercent, MinimumHealthyPercent: &minHealthyPercent}, nil
+	}
+	if rollingUpdate == nil {
+		return nil, nil
+	}
+
+	replicas := int(deploymentReplicas(d))
+	if replicas == 0 {
+		return nil, nil
+	}
+
+	// K8s defaults are 25% surge and 25% unavailable, rounded up and down to pods like the deployment controller does
+	defaultPercent := intstr.FromString("25%")
+	maxSurge, maxUnavailable := &defaultPercent, &defaultPercent
+	if rollingUpdate.MaxSurge != nil {
+		maxSurge = rollingUpdate.MaxSurge
+	}
+	if rollingUpdate.MaxUnavailable != nil {
+		maxUnavailable = rollingUpdate.MaxUnavailable
+	}
+	surge, err := intstr.GetScaledValueFromIntOrPercent(maxSurge, replicas, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxSurge: %v", err)
+	}
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, replicas, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxUnavailable: %v", err)
+	}
+
+	// ECS rounds the maximum down and the minimum healthy up to tasks, so the surge is rounded up and the unavailable
+	// down to keep the same number of tasks
+	maxPercent := int32(100 + (surge*100+replicas-1)/replicas)
+	minHealthyPercent := int32(100 - unavailable*100/replicas)
+	return &types.DeploymentConfiguration{MaximumPercent: &maxPercent, MinimumHealthyPercent: &minHealthyPercent}, nil
+}
+
+func splitList(values string) []string {
+	var result []string
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
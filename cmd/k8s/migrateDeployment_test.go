@@ -0,0 +1,57 @@
+package k8sCmd
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDeploymentConfiguration(t *testing.T) {
+	rollingUpdate := func(replicas int32, maxSurge *intstr.IntOrString, maxUnavailable *intstr.IntOrString) appsv1.Deployment {
+		var d appsv1.Deployment
+		d.Spec.Replicas = &replicas
+		d.Spec.Strategy = appsv1.DeploymentStrategy{
+			Type:          appsv1.RollingUpdateDeploymentStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: maxSurge, MaxUnavailable: maxUnavailable},
+		}
+		return d
+	}
+	value := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	var recreate appsv1.Deployment
+	recreate.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+
+	tests := []struct {
+		name              string
+		deployment        appsv1.Deployment
+		maxPercent        int32
+		minHealthyPercent int32
+		wantErr           bool
+	}{
+		{"recreate", recreate, 100, 0, false},
+		{"K8s defaults", rollingUpdate(4, nil, nil), 125, 75, false},
+		{"surge rounded up, unavailable down", rollingUpdate(3, nil, nil), 134, 100, false},
+		{"pod counts", rollingUpdate(2, value(intstr.FromInt(1)), value(intstr.FromInt(0))), 150, 100, false},
+		{"percentages", rollingUpdate(10, value(intstr.FromString("50%")), value(intstr.FromString("10%"))), 150, 90, false},
+		{"invalid surge", rollingUpdate(2, value(intstr.FromString("ten")), nil), 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc, err := deploymentConfiguration(tt.deployment)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("deploymentConfiguration() = %+v, want an error", dc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("deploymentConfiguration() error = %v", err)
+			}
+			if *dc.MaximumPercent != tt.maxPercent || *dc.MinimumHealthyPercent != tt.minHealthyPercent {
+				t.Errorf("deploymentConfiguration() = %d%%, %d%%, want %d%%, %d%%", *dc.MaximumPercent, *dc.MinimumHealthyPercent, tt.maxPercent, tt.minHealthyPercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Flags selecting the K8s cluster, read when a command first talks to it
+var (
+	kubeconfig  string
+	kubeContext string
+	kubeCluster string
+)
+
+var kubeRESTConfig *rest.Config
+
+// Adds the flags selecting the K8s cluster to a command group
+func AddKubeFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("kubeconfig", "", "Config file for the K8s cluster, defaults to the files in the KUBECONFIG environment variable, then $HOME/.kube/config, then the in-cluster config when running in a pod")
+	cmd.PersistentFlags().String("context", "", "The kubeconfig context to use, defaults to the current context")
+	cmd.PersistentFlags().String("kube-cluster", "", "The kubeconfig cluster to use, defaults to the cluster of the context")
+}
+
+// Reads the flags selecting the K8s cluster
+func ReadKubeFlags(cmd *cobra.Command) {
+	kubeconfig, _ = cmd.Flags().GetString("kubeconfig")
+	kubeContext, _ = cmd.Flags().GetString("context")
+	kubeCluster, _ = cmd.Flags().GetString("kube-cluster")
+}
+
+// Loads the config of the K8s cluster the first time it is needed, following the client-go loading rules:
+// --kubeconfig, else the KUBECONFIG paths merged, else $HOME/.kube/config, else the in-cluster config
+func KubeConfig() (*rest.Config, error) {
+	if kubeRESTConfig != nil {
+		return kubeRESTConfig, nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: kubeContext,
+		Context:        clientcmdapi.Context{Cluster: kubeCluster},
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		if clientcmd.IsEmptyConfig(err) {
+			return nil, fmt.Errorf("no kubeconfig found in %s, use --kubeconfig or run in a K8s pod", strings.Join(rules.GetLoadingPrecedence(), ", "))
+		}
+		return nil, fmt.Errorf("unable to load kubeconfig: %v", err)
+	}
+
+	// Printed to stderr to keep stdout clean for specs written with --file-name -
+	if raw, err := clientConfig.RawConfig(); err == nil && len(raw.Contexts) > 0 {
+		context := raw.CurrentContext
+		if kubeContext != "" {
+			context = kubeContext
+		}
+		fmt.Fprintln(os.Stderr, "Using kubeconfig context", context, "provided in", strings.Join(existingFiles(rules.GetLoadingPrecedence()), ", "))
+	} else {
+		fmt.Fprintln(os.Stderr, "Using in-cluster config")
+	}
+
+	kubeRESTConfig = config
+	return kubeRESTConfig, nil
+}
+
+func existingFiles(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,22 @@
+package root
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// --file-name writing the generated output to stdout
+	StdoutFileName = "-"
+
+	// Service account annotation of the IAM role assumed through IRSA
+	IRSARoleAnnotation = "eks.amazonaws.com/role-arn"
+
+	// Prefix of the warnings, generate-k8s-spec --all collects the lines starting with it
+	WarningPrefix = "Warning:"
+)
+
+// Prints something that could not be converted as is, on stderr to keep it out of the output written to stdout
+func PrintWarning(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, append([]interface{}{WarningPrefix}, a...)...)
+}
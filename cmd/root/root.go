@@ -16,6 +16,7 @@ limitations under the License.
 package root
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -25,15 +26,49 @@ import (
 
 var cfgFile string
 
+// Error exiting with another code than 1, like the errors of diff where 1 means there are changes. Nothing is printed
+// when Err is nil.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // rootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "ecs2k8s",
 	Short: "A CLI tool that will be able to migrate a running cluster from ECS to a Kubernetes cluster",
 	Long:  `ecs2k8s - A CLI tool that will be able to migrate a running cluster from ECS to a Kubernetes cluster`,
+	// Errors are printed by Execute, which also picks their exit code
+	SilenceErrors: true,
+	// Usage is only printed for flag and argument errors, which are reported before this runs
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
 }
 
 func Execute() {
-	cobra.CheckErr(RootCmd.Execute())
+	if err := RootCmd.Execute(); err != nil {
+		code := 1
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.Code
+		}
+		if err.Error() != "" {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(code)
+	}
 }
 
 func init() {